@@ -0,0 +1,284 @@
+package main
+
+import (
+	"dupefinder/src" // "dupefinder/src" as finder
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+type SizesCmd struct {
+	InputFlags
+
+	LowMemory bool `help:"search the dir twice so that the files with a unique size are never kept in memory, the same as scan --low-memory"`
+}
+
+// note that this is NOT a reliable way to find dupilcates, some filetypes have fixed size, etc.
+// but it is very fast
+func (cli *SizesCmd) Run(globals *Globals, loaded *loadedConfig) error {
+	defer globals.startProfile()()
+
+	findConfig, err := globals.findConfig()
+	if err != nil {
+		return err
+	}
+	findConfig.LowMemory = cli.LowMemory
+	fileList, err := cli.resolve(loaded)
+	if err != nil {
+		return err
+	}
+	printSizeDupes(findSizeDupes(cli.InputDir, fileList, findConfig))
+	return nil
+}
+
+func printSizeDupes(sizeDupes map[int64][]finder.FileEntry) {
+	for _, entries := range sizeDupes {
+		format := finder.FileEntryFormatter(entries)
+		fmt.Printf("%s", format)
+	}
+}
+
+type IndexCmd struct {
+	InputFlags
+
+	Output string `help:"file to write the checksums to (e.g. --algo sha256 -o SHA256SUMS); written to stdout if not given" short:"o"`
+}
+
+func (cli *IndexCmd) Run(globals *Globals, loaded *loadedConfig) error {
+	defer globals.startProfile()()
+
+	findConfig, hashConfig, err := globals.configs()
+	if err != nil {
+		return err
+	}
+	fileList, err := cli.resolve(loaded)
+	if err != nil {
+		return err
+	}
+	// empty files are part of the tree too, and verify would report them as new otherwise
+	findConfig.SkipEmpty = false
+	fileSizeMap := findFilesSizes(cli.InputDir, fileList, findConfig)
+	// dont index the manifest from an earlier run, since it is about to be replaced
+	if cli.Output != "" {
+		outputPath, _ := filepath.Abs(cli.Output)
+		for size, entries := range fileSizeMap {
+			kept := []finder.FileEntry{}
+			for _, entry := range entries {
+				if path, _ := filepath.Abs(entry.Path()); path != outputPath {
+					kept = append(kept, entry)
+				}
+			}
+			fileSizeMap[size] = kept
+		}
+	}
+	hashes := finder.HashFiles(fileSizeMap, hashConfig)
+	if cli.Output == "" {
+		err = finder.WriteChecksums(os.Stdout, hashes)
+	} else {
+		err = writeChecksumsFile(cli.Output, hashes)
+	}
+	if err != nil {
+		return err
+	}
+	// the manifest is incomplete so dont let scripts think everything was indexed
+	if unhashed := reportUnhashed(fileSizeMap, hashes); unhashed > 0 {
+		return fmt.Errorf("%v files could not be hashed and are not in the manifest", unhashed)
+	}
+	return nil
+}
+
+func writeChecksumsFile(path string, hashes map[finder.HashSum][]finder.FileHashEntry) error {
+	checksumsFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = finder.WriteChecksums(checksumsFile, hashes)
+	if closeErr := checksumsFile.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// list the files that were left out of a checksum manifest since they could not be hashed
+// otherwise they would only show up later as new files when the manifest is verified
+func reportUnhashed(fileSizeMap map[int64][]finder.FileEntry, hashes map[finder.HashSum][]finder.FileHashEntry) int {
+	unhashed := finder.UnhashedFiles(fileSizeMap, hashes)
+	for _, entry := range unhashed {
+		log.Printf("WARNING: %v could not be hashed and is not in the manifest\n", entry.Path())
+	}
+	return len(unhashed)
+}
+
+// drop the empty files from the hashes, since they are all the same and only count as duplicates with --include-empty
+func withoutEmptyFiles(hashes map[finder.HashSum][]finder.FileHashEntry) map[finder.HashSum][]finder.FileHashEntry {
+	filtered := map[finder.HashSum][]finder.FileHashEntry{}
+	for hash, entries := range hashes {
+		for _, entry := range entries {
+			if entry.File.Size > 0 {
+				filtered[hash] = append(filtered[hash], entry)
+			}
+		}
+	}
+	return filtered
+}
+
+type DiffCmd struct {
+	DirA string `help:"first dir to compare, e.g. the source of a copy" arg:"" type:"existingdir"`
+	DirB string `help:"second dir to compare, e.g. the destination of a copy" arg:"" type:"existingdir"`
+}
+
+func (cli *DiffCmd) Run(globals *Globals) error {
+	findConfig, hashConfig, err := globals.configs()
+	if err != nil {
+		return err
+	}
+	// an empty file on one side is still a difference between the dirs
+	findConfig.SkipEmpty = false
+	report := finder.CompareDirs(cli.DirA, cli.DirB, findConfig, hashConfig)
+	fmt.Printf("%s", finder.CompareFormatter(report, globals.formatConfig()))
+	log.Printf("%v files with copies, %v only in %v, %v only in %v, %v changed\n", len(report.Matches), len(report.OnlyA), cli.DirA, len(report.OnlyB), cli.DirB, len(report.Changed))
+	return nil
+}
+
+type CleanCmd struct {
+	InputDir string `help:"path to input dir to search (default: the input-dir setting in the config file)" arg:"" optional:""`
+	Remove   bool   `help:"remove everything that is listed; removals are recorded in the --journal so they can be undone"`
+}
+
+func (cli *CleanCmd) Run(globals *Globals, loaded *loadedConfig) error {
+	if cli.InputDir == "" {
+		cli.InputDir = loaded.inputDir()
+	}
+	if cli.InputDir == "" {
+		return fmt.Errorf("an input dir is required")
+	}
+	findConfig, err := globals.findConfig()
+	if err != nil {
+		return err
+	}
+	return runJunk(cli.InputDir, cli.Remove, globals, findConfig)
+}
+
+// list the files and dirs that hold no data, and optionally get rid of them
+func runJunk(inputDir string, clean bool, globals *Globals, findConfig finder.FindConfig) error {
+	report, err := finder.FindJunk(inputDir, findConfig)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s", finder.JunkFormatter(report))
+	if !clean {
+		return nil
+	}
+	journal, err := finder.OpenJournal(globals.Journal)
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+	removed, err := finder.CleanJunk(report, finder.JunkCleanConfig{Journal: journal, Verbose: globals.Verbose})
+	log.Printf("Removed %v empty files, empty dirs, and broken symlinks\n", len(removed))
+	return err
+}
+
+type VerifyCmd struct {
+	Manifest string `help:"checksum manifest to check; paths in it are relative to the current dir" arg:"" type:"existingfile"`
+	Dir      string `help:"dir to search for new files that are not in the manifest; if not given, only the files in the manifest are checked" arg:"" optional:""`
+}
+
+// the --algo flag needs to be the one that the manifest was written with
+func (cli *VerifyCmd) Run(globals *Globals) error {
+	findConfig, hashConfig, err := globals.configs()
+	if err != nil {
+		return err
+	}
+	// empty files are in the manifest too, so they need to be found to not be reported as missing
+	findConfig.SkipEmpty = false
+	manifestFile, err := os.Open(cli.Manifest)
+	if err != nil {
+		return err
+	}
+	entries, err := finder.ReadChecksums(manifestFile)
+	manifestFile.Close()
+	if err != nil {
+		return err
+	}
+
+	report, err := finder.VerifyChecksums(entries, cli.Dir, findConfig, hashConfig)
+	if err != nil {
+		return fmt.Errorf("%v; use --algo to give the algorithm that the manifest was written with", err)
+	}
+	// the manifest is usually kept in the dir that it covers
+	manifestPath, _ := filepath.Abs(cli.Manifest)
+	newFiles := []finder.FileEntry{}
+	for _, entry := range report.New {
+		if path, _ := filepath.Abs(entry.Path()); path != manifestPath {
+			newFiles = append(newFiles, entry)
+		}
+	}
+	report.New = newFiles
+
+	fmt.Printf("%s", finder.VerifyFormatter(report))
+	log.Printf("%v files OK, %v changed, %v missing, %v unreadable, %v new\n", report.NumOK, len(report.Mismatched), len(report.Missing), len(report.Unreadable), len(report.New))
+	if report.Failed() {
+		return fmt.Errorf("verification failed")
+	}
+	return nil
+}
+
+type ConsolidateCmd struct {
+	Sources  []string `help:"dirs to merge into the destination, in order of preference; files keep their path relative to the dir they came from" arg:"" type:"existingdir"`
+	Dest     string   `help:"dir to store the files in; it is created if needed and the files already in it are never stored again" required:""`
+	Move     bool     `help:"move the files into the destination instead of copying them (use a dir on the same volume); files whose contents are already stored are left in place"`
+	Manifest string   `help:"path of the tab separated manifest of where each source file's contents are stored (default: dupefinder-consolidate.tsv in the destination)"`
+}
+
+func (cli *ConsolidateCmd) Run(globals *Globals) error {
+	findConfig, hashConfig, err := globals.configs()
+	if err != nil {
+		return err
+	}
+	// empty files are still files that should end up in the destination
+	findConfig.SkipEmpty = false
+	consolidateConfig := finder.ConsolidateConfig{
+		Dest:     cli.Dest,
+		Move:     cli.Move,
+		Manifest: cli.Manifest,
+		Algo:     globals.Algo,
+		Verbose:  globals.Verbose,
+	}
+	if cli.Move {
+		journal, err := finder.OpenJournal(globals.Journal)
+		if err != nil {
+			return err
+		}
+		defer journal.Close()
+		consolidateConfig.Journal = journal
+	}
+
+	records, err := finder.Consolidate(cli.Sources, findConfig, hashConfig, consolidateConfig)
+	counts := map[string]int{}
+	for _, record := range records {
+		counts[record.Status] += 1
+	}
+	log.Printf("Stored %v files, skipped %v already in %v, skipped %v duplicates\n",
+		counts[finder.ConsolidateCopied]+counts[finder.ConsolidateMoved], counts[finder.ConsolidateExisting], cli.Dest, counts[finder.ConsolidateDuplicate])
+	return err
+}
+
+type UndoCmd struct {
+	// the global --journal flag is where new actions get recorded, so this one is positional
+	JournalFile string `help:"path to the journal file to undo" arg:"" type:"existingfile" name:"journal"`
+	DryRun      bool   `help:"only print the actions that would be undone"`
+}
+
+func (cli *UndoCmd) Run(globals *Globals) error {
+	undoConfig := finder.UndoConfig{DryRun: cli.DryRun, Verbose: globals.Verbose}
+	undone, err := finder.UndoJournal(cli.JournalFile, undoConfig)
+	if err != nil {
+		return err
+	}
+	if !cli.DryRun {
+		log.Printf("Undid %v actions from journal %v\n", len(undone), cli.JournalFile)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"dupefinder/src" // "dupefinder/src" as finder
+	"fmt"
+	"github.com/alecthomas/kong"
+	"os"
+	"strings"
+)
+
+// the config file that was loaded for this run, if any; bound so that the commands can use the settings
+// that kong cannot fill in by itself
+type loadedConfig struct {
+	config  finder.Config
+	profile string
+}
+
+// get the input dir setting for the commands that search a dir
+func (loaded *loadedConfig) inputDir() string {
+	value, _ := loaded.config.Lookup(loaded.profile, "input-dir")
+	inputDir, _ := value.(string)
+	return kong.ExpandPath(inputDir)
+}
+
+// kong resolver that sets the flags that were not given on the command line from the config file
+type configResolver struct {
+	config  finder.Config
+	profile string
+}
+
+// check that the config only has settings for flags and args that exist
+func (resolver configResolver) Validate(app *kong.Application) error {
+	known := []string{}
+	var addNames func(node *kong.Node)
+	addNames = func(node *kong.Node) {
+		for _, flag := range node.Flags {
+			if flag.Name != "config" && flag.Name != "config-profile" && flag.Name != "help" {
+				known = append(known, flag.Name)
+			}
+		}
+		for _, child := range node.Children {
+			addNames(child)
+		}
+	}
+	addNames(app.Node)
+	known = append(known, "input-dir")
+	return resolver.config.Validate(known)
+}
+
+func (resolver configResolver) Resolve(context *kong.Context, parent *kong.Path, flag *kong.Flag) (interface{}, error) {
+	value, ok := resolver.config.Lookup(resolver.profile, flag.Name)
+	if !ok {
+		return nil, nil
+	}
+	return value, nil
+}
+
+// load the config file before the flags get their values so that its settings are used for the flags that were not given
+// a missing config file is only an error if it was asked for with --config or --config-profile
+func (cli *CLI) BeforeResolve(ctx *kong.Context) error {
+	path := finder.DefaultConfigPath
+	var profile string
+	var explicit bool
+	for _, flag := range ctx.Flags() {
+		switch flag.Name {
+		case "config":
+			if value, _ := ctx.FlagValue(flag).(string); value != "" {
+				path = value
+				explicit = true
+			}
+		case "config-profile":
+			profile, _ = ctx.FlagValue(flag).(string)
+		}
+	}
+	loaded := &loadedConfig{profile: profile}
+	ctx.Bind(loaded)
+
+	file, err := os.Open(kong.ExpandPath(path))
+	if os.IsNotExist(err) && !explicit && profile == "" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	config, err := finder.ReadConfig(file)
+	if err != nil {
+		return fmt.Errorf("could not read config file %v: %v", path, err)
+	}
+	if _, ok := config.Profiles[profile]; profile != "" && !ok {
+		return fmt.Errorf("profile %q is not in config file %v; the profiles are: %v", profile, path, strings.Join(config.ProfileNames(), ", "))
+	}
+	loaded.config = config
+	ctx.AddResolver(configResolver{config: config, profile: profile})
+	return nil
+}
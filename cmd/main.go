@@ -5,113 +5,193 @@ import (
 	"fmt"
 	"github.com/alecthomas/kong"
 	"log"
+	"os"
 	"runtime/pprof"
+	"time"
 )
 
 type CLI struct {
-	InputDir   string `help:"path to input file to search" arg:""`
-	IgnoreFile string `help:"path to file of dir paths to ignore"`
-	PrintSize  bool   `help:"print the file size (hint: pipe to 'sort -k2,2n')"`
-	Parallel   int    `help:"number of files to hash in parallel (only use value >1 with SSD)" default:"1"`
-	Profile    bool   `help:"enable profiling and outputs files for use with
-'go tool pprof cpu.prof' (hint: use the 'top' command in pprof to see resource usages)"`
-	HashBytes int64  `help:"number of bytes to hash for each duplicated file; example: 1000 = 1KB, 1000000 = 1MB, 1000000000 = 1GB"`
-	Algo      string `help:"hashing algorithm to use. Options (fastest to slowest): xxhash, sha1, md5, sha256" default:"md5"`
-	SizeOnly  bool   `help:"only look for duplicates based on file size"`
-	MinSize   int64  `help:"only include files of minimum size (bytes) or larger when searching"`
-	// NOTE: note sure how to get Kong to accept type of *int64 here for MaxSize;
-	MaxSize int64 `help:"only include files of maximum size (bytes) or smaller when searching. Value must be >0, value of 0 = disabled" default:"0"`
-	Debug   bool  `help:"only used for dev debug purposes! Don't use this option it doesnt do anything"`
-	Verbose bool  `help:"print messages to stderr while processing files"` // false by default
+	Globals
+
+	Scan        ScanCmd        `cmd:"" default:"withargs" help:"search a directory for duplicate files (default command)"`
+	Sizes       SizesCmd       `cmd:"" help:"list the files that have the same size as another file, without hashing anything; fast, but files with the same size are not always the same"`
+	Index       IndexCmd       `cmd:"" help:"hash every file and write the hashes in the same format as sha256sum and md5sum, for use with the 'verify' command"`
+	Diff        DiffCmd        `cmd:"" aliases:"compare" help:"compare the contents of two directories, listing the files with a copy on the other side, the files only on one side, and the files that changed at the same path"`
+	Clean       CleanCmd       `cmd:"" help:"list empty files, dirs with no files anywhere below them, and broken symlinks, and optionally remove them"`
+	Verify      VerifyCmd      `cmd:"" help:"re-hash the files in a checksum manifest written with 'index' or --write-checksums (or sha256sum, md5sum) and report the files that changed, went missing, or are new"`
+	Serve       ServeCmd       `cmd:"" help:"serve the duplicates in a directory over HTTP as text or JSON, searching again for each request"`
+	Consolidate ConsolidateCmd `cmd:"" help:"copy or move one copy of each file's contents from one or more directories into a destination directory, skipping contents that are already there"`
+	Undo        UndoCmd        `cmd:"" help:"undo the actions recorded in a journal file, newest first"`
 }
 
-func (cli *CLI) Run() error {
-	err := run(
-		cli.InputDir,
-		cli.IgnoreFile,
-		cli.PrintSize,
-		cli.Parallel,
-		cli.Profile,
-		cli.HashBytes,
-		cli.Algo,
-		cli.MinSize,
-		cli.SizeOnly,
-		cli.MaxSize,
-		cli.Debug,
-		cli.Verbose,
-	)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	return nil
+// flags that are shared by all the commands
+type Globals struct {
+	Config        string `help:"config file to read settings and profiles from (default: ~/.config/dupefinder/config)" type:"path"`
+	ConfigProfile string `help:"name of the profile in the config file to use, e.g. photos for the settings in [profiles.photos]; the --profile flag is for pprof"`
+	PrintSize     bool   `help:"print the file size (hint: pipe to 'sort -k2,2n')"`
+	Parallel      int    `help:"number of files to hash in parallel (only use value >1 with SSD)" default:"1"`
+	Profile       bool   `help:"enable profiling and outputs files for use with 'go tool pprof cpu.prof' (hint: use the 'top' command in pprof to see resource usages)"`
+	Algo          string `help:"hashing algorithm to use. Options (roughly fastest to slowest): xxhash, crc32, crc32c, crc64, fnv64a, fnv128a, sha1, md5, blake2b, blake2b-512, sha512, sha256" default:"md5"`
+	MinSize       int64  `help:"only include files of minimum size (bytes) or larger when searching"`
+	// NOTE: note sure how to get Kong to accept type of *int64 here for MaxSize;
+	MaxSize      int64    `help:"only include files of maximum size (bytes) or smaller when searching. Value must be >0, value of 0 = disabled" default:"0"`
+	NewerThan    string   `help:"only include files modified after this date (e.g. 2022-01-31) or less than this long ago (e.g. 30d, 2w, 12h)"`
+	OlderThan    string   `help:"only include files modified before this date (e.g. 2022-01-31) or more than this long ago (e.g. 30d, 2w, 12h)"`
+	IncludeExt   []string `help:"only include files with these extensions (comma separated, e.g. jpg,png,mp4)"`
+	ExcludeExt   []string `help:"skip files with these extensions (comma separated, e.g. log,tmp)"`
+	IncludeGlob  []string `help:"only include files whose name matches this shell glob, or whose relative path matches it if it contains a '/'; can be given more than once" sep:"none"`
+	ExcludeGlob  []string `help:"skip files whose name matches this shell glob, or whose relative path matches it if it contains a '/'; can be given more than once" sep:"none"`
+	IncludeRegex []string `help:"only include files whose relative path matches this regular expression; can be given more than once" sep:"none"`
+	ExcludeRegex []string `help:"skip files whose relative path matches this regular expression; can be given more than once" sep:"none"`
+	MinDepth     int      `help:"only include files at least this many dirs deep; files directly in the input dir have depth 1"`
+	MaxDepth     int      `help:"only include files at most this many dirs deep; files directly in the input dir have depth 1, value of 0 = disabled" default:"0"`
+	SkipHidden   bool     `help:"skip hidden files and dirs (names starting with '.')"`
+	IncludeEmpty bool     `help:"include zero byte files; they are skipped by default since they are all the same (see the 'clean' command)"`
+	Journal      string   `help:"journal file to record every file that gets moved, deleted, or replaced, for use with the 'undo' command" default:"dupefinder-journal.jsonl"`
+	Verbose      bool     `help:"print messages to stderr while processing files"` // false by default
 }
 
-func run(
-	inputDir string,
-	ignoreFile string,
-	printSize bool,
-	numWorkers int,
-	enableProfile bool,
-	hashBytes int64,
-	algo string,
-	minSize int64,
-	sizeOnly bool,
-	maxSize int64,
-	debug bool,
-	verbose bool,
-) error {
-	// fmt.Printf("verbose: %v\n", verbose)
-
-	if enableProfile {
-		cpuFile, memFile := finder.StartProfiler()
-		defer cpuFile.Close()
-		defer memFile.Close()
-		defer pprof.StopCPUProfile()
-	}
-
-	findConfig := finder.FindConfig{MinSize: minSize, Verbose: verbose} // var skipDirs = []string{} // ignoreFile goes here
+// get the settings for searching for files from the filter flags
+func (globals *Globals) findConfig() (finder.FindConfig, error) {
+	findConfig := finder.FindConfig{
+		MinSize:    globals.MinSize,
+		MinDepth:   globals.MinDepth,
+		MaxDepth:   globals.MaxDepth,
+		SkipHidden: globals.SkipHidden,
+		SkipEmpty:  !globals.IncludeEmpty,
+		Verbose:    globals.Verbose,
+	}
 
 	// NOTE: not sure how to get Kong to accept type of *int64 here for MaxSize
 	// TODO: fix this handling when future release of Kong can support *int64 to be able to use nil as default value
-	if maxSize > 0 {
+	if globals.MaxSize > 0 {
+		maxSize := globals.MaxSize
 		findConfig.MaxSize = &maxSize
 	}
 
-	hashConfig := finder.HashConfig{NumWorkers: numWorkers, Algo: algo, Verbose: verbose}
-	if hashBytes > 0 {
-		hashConfig.Partial = true
-		hashConfig.NumBytes = hashBytes
+	now := time.Now()
+	if globals.NewerThan != "" {
+		t, err := finder.ParseTimeBound(globals.NewerThan, now)
+		if err != nil {
+			return findConfig, err
+		}
+		findConfig.NewerThan = &t
+	}
+	if globals.OlderThan != "" {
+		t, err := finder.ParseTimeBound(globals.OlderThan, now)
+		if err != nil {
+			return findConfig, err
+		}
+		findConfig.OlderThan = &t
+	}
+
+	include, err := finder.NewPathFilter(globals.IncludeExt, globals.IncludeGlob, globals.IncludeRegex)
+	if err != nil {
+		return findConfig, err
+	}
+	exclude, err := finder.NewPathFilter(globals.ExcludeExt, globals.ExcludeGlob, globals.ExcludeRegex)
+	if err != nil {
+		return findConfig, err
+	}
+	findConfig.Include = include
+	findConfig.Exclude = exclude
+	return findConfig, nil
+}
+
+// get the settings for hashing whole files
+// the algorithm is checked here so that we dont spend time searching for files with one that does not exist
+func (globals *Globals) hashConfig() (finder.HashConfig, error) {
+	hashConfig := finder.HashConfig{NumWorkers: globals.Parallel, Algo: globals.Algo, Verbose: globals.Verbose}
+	_, err := finder.GetHashAlgorithm(globals.Algo)
+	return hashConfig, err
+}
+
+// get both configs, for the commands that search for files and hash them
+func (globals *Globals) configs() (finder.FindConfig, finder.HashConfig, error) {
+	findConfig, err := globals.findConfig()
+	if err != nil {
+		return findConfig, finder.HashConfig{}, err
 	}
+	hashConfig, err := globals.hashConfig()
+	return findConfig, hashConfig, err
+}
 
-	formatConfig := finder.FormatConfig{Size: printSize}
+func (globals *Globals) formatConfig() finder.FormatConfig {
+	return finder.FormatConfig{Size: globals.PrintSize}
+}
 
-	if debug {
-		// change the commands here to use when debugging and benchmarking stuff, etc..
-		finder.FindFilesSizes(inputDir, findConfig)
-		return nil
+// start profiling if it was asked for; call the returned func when the command is done
+func (globals *Globals) startProfile() func() {
+	if !globals.Profile {
+		return func() {}
+	}
+	cpuFile, memFile := finder.StartProfiler()
+	return func() {
+		pprof.StopCPUProfile()
+		cpuFile.Close()
+		memFile.Close()
 	}
+}
+
+// the files that a command should look at, from a dir or from a list of paths
+type InputFlags struct {
+	InputDir  string `help:"path to input dir to search; with --files-from, quarantined paths are kept relative to it and the glob and regex filters match paths relative to it (default: current dir)" arg:"" optional:""`
+	FilesFrom string `help:"read the paths of the files to check from this file instead of searching the input dir, one per line; use - for stdin (e.g. find . -name '*.jpg' | dupefinder --files-from -)"`
+	Null      bool   `help:"paths in the --files-from list are separated by NUL characters instead of newlines (e.g. find -print0)" short:"0"`
+}
 
-	// check if we only want to search for files with dupilcate byte size
-	// note that this is NOT a reliable way to find dupilcates, some filetypes have fixed size, etc.
-	// but it is very fast
-	if sizeOnly {
-		fileSizeMap, _ := finder.FindFilesSizes(inputDir, findConfig)
-		sizeDupes, _ := finder.FindSizeDupes(fileSizeMap)
-		for _, entries := range sizeDupes {
-			format := finder.FileEntryFormatter(entries)
-			fmt.Printf("%s", format)
+// fill in the input dir from the config file and read the --files-from list if there is one
+// returns a nil list when the input dir should be searched instead
+func (input *InputFlags) resolve(loaded *loadedConfig) ([]string, error) {
+	// the input dir is a positional arg so kong does not fill it in from the config
+	if input.InputDir == "" && input.FilesFrom == "" {
+		input.InputDir = loaded.inputDir()
+	}
+	if input.FilesFrom == "" {
+		if input.InputDir == "" {
+			return nil, fmt.Errorf("an input dir or --files-from is required")
 		}
+		return nil, nil
+	}
 
-		// do the full hash checking search instead
-	} else {
-		dupes, _ := finder.FindDupes(inputDir, findConfig, hashConfig)
-		for _, entries := range dupes {
-			format := finder.DupesFormatter(entries, formatConfig)
-			fmt.Printf("%s", format) // format has newline embedded at the end
+	listFile := os.Stdin
+	if input.FilesFrom != "-" {
+		var err error
+		listFile, err = os.Open(input.FilesFrom)
+		if err != nil {
+			return nil, err
 		}
+		defer listFile.Close()
+	}
+	fileList, err := finder.ReadFileList(listFile, input.Null)
+	if err != nil {
+		return nil, err
 	}
+	// quarantined files keep their path relative to this dir
+	if input.InputDir == "" {
+		input.InputDir = "."
+	}
+	return fileList, nil
+}
+
+// get the files to check grouped by size, from the --files-from list if there is one, otherwise by searching the input dir
+func findFilesSizes(inputDir string, fileList []string, findConfig finder.FindConfig) map[int64][]finder.FileEntry {
+	if fileList != nil {
+		fileSizeMap, _ := finder.FindListFilesSizes(fileList, inputDir, findConfig)
+		return fileSizeMap
+	}
+	fileSizeMap, _ := finder.FindFilesSizes(inputDir, findConfig)
+	return fileSizeMap
+}
 
-	return nil
+// get the files that have the same size as another file, without keeping the other files in memory with --low-memory
+func findSizeDupes(inputDir string, fileList []string, findConfig finder.FindConfig) map[int64][]finder.FileEntry {
+	if fileList == nil && findConfig.LowMemory {
+		sizeDupes, _ := finder.FindSizeDupeFiles(inputDir, findConfig)
+		return sizeDupes
+	}
+	sizeDupes, _ := finder.FindSizeDupes(findFilesSizes(inputDir, fileList, findConfig))
+	return sizeDupes
 }
 
 func main() {
@@ -121,6 +201,8 @@ func main() {
 		kong.Name("Duplicate File Finder"),
 		kong.Description("Program for finding duplicate files in a directory"))
 
-	ctx.FatalIfErrorf(ctx.Run())
-
+	err := ctx.Run(&cli.Globals)
+	if err != nil {
+		log.Fatalln(err)
+	}
 }
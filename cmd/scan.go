@@ -0,0 +1,345 @@
+package main
+
+import (
+	"dupefinder/src" // "dupefinder/src" as finder
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+type ScanCmd struct {
+	InputFlags
+
+	IgnoreFile     string  `help:"path to file of dir paths to ignore"`
+	HashBytes      int64   `help:"number of bytes to hash for each duplicated file; example: 1000 = 1KB, 1000000 = 1MB, 1000000000 = 1GB"`
+	Sample         string  `help:"which bytes to hash when using --hash-bytes; head = first n bytes, tail = last n bytes, head-tail = both, spread = head, tail, and evenly spaced blocks in between" enum:"head,tail,head-tail,spread" default:"head"`
+	SampleBlocks   int     `help:"number of evenly spaced blocks to hash between the head and tail with --sample=spread" default:"4"`
+	SizeOnly       bool    `help:"only look for duplicates based on file size (same as the 'sizes' command)"`
+	NormalizeText  bool    `help:"treat text files as duplicates when they only differ in line endings (CRLF vs LF), trailing whitespace, or a UTF-8 BOM; all text files get hashed, not just ones with the same size"`
+	Decompress     bool    `help:"compare .gz and .bz2 files by their decompressed contents so that they match uncompressed copies (e.g. data.csv and data.csv.gz); every compressed file gets decompressed to find its size"`
+	Images         bool    `help:"look for the same pictures saved at different sizes, qualities, or formats (JPEG, PNG, GIF) by comparing perceptual hashes instead of file contents; only reports the groups"`
+	ImageHash      string  `help:"perceptual hash to use with --images; ahash = fastest, dhash = gradients, phash = most robust to resizing and re-encoding" enum:"ahash,dhash,phash" default:"phash"`
+	ImageDistance  int     `help:"max number of bits (out of 64) that can differ between two image hashes for --images to group the pictures together; 0 = identical hashes only" default:"8"`
+	Similar        bool    `help:"look for pairs of files that share most of their contents (e.g. VM images, database dumps, logs) by splitting every file into content defined chunks; prints the percentage of the larger file that is shared, the shared bytes, and both paths"`
+	SimilarPercent float64 `help:"min percentage of the larger file that two files must share to be reported by --similar" default:"50"`
+	ChunkSize      int     `help:"average chunk size in bytes for --similar; smaller chunks find more overlap but use more memory" default:"8192"`
+	ChunkMaxFiles  int     `help:"chunks that are in more than this many files (e.g. blocks of zeros or common headers) are not counted as shared by --similar, since every pair of those files would need to be compared" default:"100"`
+	SameName       bool    `help:"report file names that appear in more than one place with different contents; copies with the same contents share a version number (e.g. v1)"`
+	ReportJunk     bool    `help:"list empty files, dirs with no files anywhere below them, and broken symlinks instead of looking for duplicates (same as the 'clean' command)"`
+	CleanJunk      bool    `help:"remove everything listed by --report-junk; removals are recorded in the --journal so they can be undone"`
+	SpaceReport    bool    `help:"instead of listing the duplicates, show which dirs hold the most reclaimable space and which pairs of dirs share the most duplicated data (hint: the sizes can be sorted with 'sort -h')"`
+	SpaceDepth     int     `help:"number of dir levels below the input dir to add up the space in for --space-report; deeper dirs are counted in their ancestor, value of 0 = every dir separately" default:"0"`
+	SpaceTop       int     `help:"number of dirs and dir pairs to show with --space-report, value of 0 = all" default:"20"`
+	WriteChecksums string  `help:"hash every file that was searched, not just the duplicates, and write the hashes to this file in the same format as sha256sum and md5sum, for use with the 'verify' command (e.g. --algo sha256 --write-checksums SHA256SUMS)"`
+	Quarantine     string  `help:"move the redundant copies of each set of duplicates into this dir, keeping their path relative to the input dir, and write a manifest there (use a dir on the same volume)"`
+	Link           string  `help:"replace the redundant copies of each set of duplicates with links to the kept copy; hardlink = hardlinks, reflink = copy-on-write clones that share storage but stay independent files (Linux btrfs/XFS only), symlink = symlinks, which work across filesystems" enum:",hardlink,reflink,symlink" default:""`
+	Symlinks       string  `help:"type of path to use for the target of symlinks made with --link=symlink" enum:"absolute,relative" default:"absolute"`
+	EmitScript     string  `help:"write a shell script with the commands for the chosen action (rm by default, or mv for --quarantine, ln for --link) to this file instead of running them, for review before running it yourself"`
+	ScriptActive   bool    `help:"write the commands in the --emit-script file uncommented so that the script runs them as is; groups matched by a sampled, normalized, or decompressed hash stay commented out"`
+	LowMemory      bool    `help:"search the dir twice, first only counting the file sizes, so that the files with a unique size are never kept in memory; for trees with tens of millions of files, at the cost of a second search"`
+	Interactive    bool    `help:"go through each set of duplicates and choose which copies to keep; the others get deleted, or linked using the --link type"`
+	Debug          bool    `help:"only used for dev debug purposes! Don't use this option it doesnt do anything"`
+}
+
+func (cli *ScanCmd) Run(globals *Globals, loaded *loadedConfig) error {
+	defer globals.startProfile()()
+
+	findConfig, hashConfig, err := globals.configs()
+	if err != nil {
+		return err
+	}
+	// dont search through files that were already quarantined
+	// the dir is compared by its absolute path, since skipping it by name would also skip other dirs with the same name
+	if cli.Quarantine != "" {
+		quarantineDir, err := filepath.Abs(cli.Quarantine)
+		if err != nil {
+			return err
+		}
+		findConfig.SkipPaths = append(findConfig.SkipPaths, quarantineDir)
+	}
+	findConfig.LowMemory = cli.LowMemory
+	hashConfig.NormalizeText = cli.NormalizeText
+	hashConfig.Decompress = cli.Decompress
+	if cli.HashBytes > 0 {
+		hashConfig.Partial = true
+		hashConfig.NumBytes = cli.HashBytes
+		hashConfig.Sample = cli.Sample
+		hashConfig.NumBlocks = cli.SampleBlocks
+	}
+
+	if cli.Interactive && cli.FilesFrom == "-" {
+		return fmt.Errorf("--interactive cannot be used with --files-from reading from stdin")
+	}
+	// read the paths to check instead of searching the input dir
+	fileList, err := cli.resolve(loaded)
+	if err != nil {
+		return err
+	}
+	if err := cli.validate(); err != nil {
+		return err
+	}
+
+	switch {
+	case cli.Debug:
+		// change the commands here to use when debugging and benchmarking stuff, etc..
+		finder.FindFilesSizes(cli.InputDir, findConfig)
+		return nil
+	case cli.Images:
+		return cli.runImages(globals, fileList, findConfig)
+	case cli.Similar:
+		return cli.runSimilar(globals, fileList, findConfig)
+	case cli.ReportJunk:
+		return runJunk(cli.InputDir, cli.CleanJunk, globals, findConfig)
+	case cli.SameName:
+		fileSizeMap := findFilesSizes(cli.InputDir, fileList, findConfig)
+		for _, group := range finder.FindSameNameFiles(fileSizeMap, hashConfig) {
+			fmt.Printf("%s", finder.NameGroupFormatter(group, globals.formatConfig()))
+		}
+		return nil
+	case cli.SizeOnly:
+		printSizeDupes(findSizeDupes(cli.InputDir, fileList, findConfig))
+		return nil
+	}
+
+	dupes, err := cli.findDupes(fileList, findConfig, hashConfig)
+	if err != nil {
+		return err
+	}
+	// add up where the duplicates are instead of listing them
+	if cli.SpaceReport {
+		report := finder.SpaceBreakdown(dupes, finder.SpaceConfig{Root: cli.InputDir, Depth: cli.SpaceDepth})
+		fmt.Printf("%s", finder.SpaceReportFormatter(report, cli.SpaceTop))
+		return nil
+	}
+	// the interactive mode shows the duplicates itself
+	if !cli.Interactive {
+		for _, entries := range dupes {
+			format := finder.DupesFormatter(entries, globals.formatConfig())
+			fmt.Printf("%s", format) // format has newline embedded at the end
+		}
+	}
+	// write out the commands for the action instead of doing it
+	if cli.EmitScript != "" {
+		return cli.writeScript(dupes)
+	}
+	return cli.act(dupes, globals)
+}
+
+// check that the flags for the modes and actions can be used together
+func (cli *ScanCmd) validate() error {
+	actions := cli.Quarantine != "" || cli.Link != "" || cli.EmitScript != "" || cli.Interactive
+
+	// only one action can be taken on the duplicates
+	if cli.Quarantine != "" && cli.Link != "" {
+		return fmt.Errorf("--quarantine and --link cannot be used together")
+	}
+	if cli.Interactive && (cli.Quarantine != "" || cli.EmitScript != "") {
+		return fmt.Errorf("--interactive cannot be used with --quarantine or --emit-script")
+	}
+	// the copies are not byte for byte the same so replacing them would lose data that cannot be undone
+	if (cli.NormalizeText || cli.Decompress) && (cli.Link != "" || cli.Interactive) {
+		return fmt.Errorf("--normalize-text and --decompress cannot be used with --link or --interactive; use --quarantine or --emit-script to review the copies instead")
+	}
+	// only part of each file is compared so the rest of the copies may differ
+	if cli.HashBytes > 0 && (cli.Link != "" || cli.Interactive) {
+		return fmt.Errorf("--hash-bytes cannot be used with --link or --interactive; use --quarantine or --emit-script to review the copies instead")
+	}
+	// the sampled bytes are raw compressed data, which never lines up with the same part of an uncompressed copy
+	if cli.HashBytes > 0 && cli.Decompress {
+		return fmt.Errorf("--hash-bytes cannot be used with --decompress")
+	}
+	// similar looking images and similar files are not the same file so never act on them
+	if cli.Images && actions {
+		return fmt.Errorf("--images only reports similar images and cannot be used with --quarantine, --link, --emit-script, or --interactive")
+	}
+	if cli.Similar && actions {
+		return fmt.Errorf("--similar only reports similar files and cannot be used with --quarantine, --link, --emit-script, or --interactive")
+	}
+	if cli.SameName && actions {
+		return fmt.Errorf("--same-name only reports files and cannot be used with --quarantine, --link, --emit-script, or --interactive")
+	}
+	if cli.CleanJunk && !cli.ReportJunk {
+		return fmt.Errorf("--clean-junk needs --report-junk")
+	}
+	if cli.ReportJunk && (actions || cli.FilesFrom != "") {
+		return fmt.Errorf("--report-junk cannot be used with --quarantine, --link, --emit-script, --interactive, or --files-from")
+	}
+	if cli.SpaceReport && (cli.SizeOnly || actions) {
+		return fmt.Errorf("--space-report only reports the space used and cannot be used with --size-only, --quarantine, --link, --emit-script, or --interactive")
+	}
+	// the manifest needs the hash of the whole file as it is on disk
+	if cli.WriteChecksums != "" && (cli.HashBytes > 0 || cli.NormalizeText || cli.Decompress || cli.SizeOnly) {
+		return fmt.Errorf("--write-checksums cannot be used with --hash-bytes, --normalize-text, --decompress, or --size-only")
+	}
+	if cli.WriteChecksums != "" && (cli.Images || cli.Similar || cli.SameName || cli.ReportJunk) {
+		return fmt.Errorf("--write-checksums cannot be used with --images, --similar, --same-name, or --report-junk")
+	}
+	// every file is needed for these, not only the ones whose size is shared
+	if cli.LowMemory && (cli.FilesFrom != "" || cli.NormalizeText || cli.Decompress || cli.WriteChecksums != "" || cli.Images || cli.Similar || cli.SameName) {
+		return fmt.Errorf("--low-memory cannot be used with --files-from, --normalize-text, --decompress, --write-checksums, --images, --similar, or --same-name")
+	}
+	var numModes int
+	for _, mode := range []bool{cli.Images, cli.Similar, cli.SameName, cli.ReportJunk, cli.SpaceReport} {
+		if mode {
+			numModes += 1
+		}
+	}
+	if numModes > 1 {
+		return fmt.Errorf("only one of --images, --similar, --same-name, --report-junk, or --space-report can be used at a time")
+	}
+	return nil
+}
+
+// look for pictures that look the same instead of files with the same contents
+// every image gets compared so there is no grouping by size first
+func (cli *ScanCmd) runImages(globals *Globals, fileList []string, findConfig finder.FindConfig) error {
+	fileSizeMap := findFilesSizes(cli.InputDir, fileList, findConfig)
+	imageConfig := finder.ImageConfig{
+		Method:      cli.ImageHash,
+		MaxDistance: cli.ImageDistance,
+		NumWorkers:  globals.Parallel,
+		Verbose:     globals.Verbose,
+	}
+	for _, group := range finder.FindImageDupes(fileSizeMap, imageConfig) {
+		fmt.Printf("%s", finder.ImageDupesFormatter(group, globals.formatConfig()))
+	}
+	return nil
+}
+
+// look for files that share most of their contents; every file gets compared so there is no grouping by size first
+func (cli *ScanCmd) runSimilar(globals *Globals, fileList []string, findConfig finder.FindConfig) error {
+	fileSizeMap := findFilesSizes(cli.InputDir, fileList, findConfig)
+	similarConfig := finder.SimilarConfig{
+		MinPercent:    cli.SimilarPercent,
+		ChunkSize:     cli.ChunkSize,
+		MaxChunkFiles: cli.ChunkMaxFiles,
+		NumWorkers:    globals.Parallel,
+		Verbose:       globals.Verbose,
+	}
+	for _, pair := range finder.FindSimilarFiles(fileSizeMap, similarConfig) {
+		fmt.Printf("%s", finder.SimilarPairFormatter(pair))
+	}
+	return nil
+}
+
+// hash the files to find the duplicates, writing the checksum manifest on the way if asked for
+func (cli *ScanCmd) findDupes(fileList []string, findConfig finder.FindConfig, hashConfig finder.HashConfig) (map[finder.HashSum][]finder.FileHashEntry, error) {
+	if cli.WriteChecksums != "" {
+		// every file gets hashed for the manifest, so use the same hashes to find the duplicates
+		// the manifest always has the empty files, but they are only reported as duplicates with --include-empty
+		skipEmpty := findConfig.SkipEmpty
+		findConfig.SkipEmpty = false
+		fileSizeMap := findFilesSizes(cli.InputDir, fileList, findConfig)
+		hashes := finder.HashFiles(fileSizeMap, hashConfig)
+		if err := writeChecksumsFile(cli.WriteChecksums, hashes); err != nil {
+			return nil, err
+		}
+		reportUnhashed(fileSizeMap, hashes)
+		if skipEmpty {
+			hashes = withoutEmptyFiles(hashes)
+		}
+		return finder.FilterHashDupes(hashes, hashConfig), nil
+	}
+	if fileList != nil {
+		dupes, _ := finder.FindListDupes(fileList, cli.InputDir, findConfig, hashConfig)
+		return dupes, nil
+	}
+	dupes, _ := finder.FindDupes(cli.InputDir, findConfig, hashConfig)
+	return dupes, nil
+}
+
+func (cli *ScanCmd) writeScript(dupes map[finder.HashSum][]finder.FileHashEntry) error {
+	scriptConfig := finder.ScriptConfig{
+		Action:           finder.ScriptRemove,
+		Active:           cli.ScriptActive,
+		RelativeSymlinks: cli.Symlinks == "relative",
+		Root:             cli.InputDir,
+		MoveDir:          cli.Quarantine,
+	}
+	switch {
+	case cli.Quarantine != "":
+		scriptConfig.Action = finder.ScriptMove
+	case cli.Link == finder.LinkHardlink:
+		scriptConfig.Action = finder.ScriptHardlink
+	case cli.Link == finder.LinkSymlink:
+		scriptConfig.Action = finder.ScriptSymlink
+	case cli.Link != "":
+		return fmt.Errorf("--link=%v cannot be written to a script", cli.Link)
+	}
+
+	scriptFile, err := os.Create(cli.EmitScript)
+	if err != nil {
+		return err
+	}
+	defer scriptFile.Close()
+	return finder.WriteScript(scriptFile, dupes, scriptConfig)
+}
+
+// run the chosen action on the duplicates, recording it in the journal
+func (cli *ScanCmd) act(dupes map[finder.HashSum][]finder.FileHashEntry, globals *Globals) error {
+	if cli.Quarantine == "" && cli.Link == "" && !cli.Interactive {
+		return nil
+	}
+	journal, err := finder.OpenJournal(globals.Journal)
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+
+	// ask the user what to do with each group; --link sets how copies get linked
+	if cli.Interactive {
+		interactiveConfig := finder.InteractiveConfig{
+			In:               os.Stdin,
+			Out:              os.Stdout,
+			LinkMode:         cli.Link,
+			RelativeSymlinks: cli.Symlinks == "relative",
+			Journal:          journal,
+			Algo:             globals.Algo,
+		}
+		summary, err := finder.InteractiveDupes(dupes, interactiveConfig)
+		if err != nil {
+			return err
+		}
+		log.Printf("Deleted %v files, linked %v files, skipped %v groups, %v errors\n", summary.Deleted, summary.Linked, summary.Skipped, summary.Errors)
+		return nil
+	}
+
+	if cli.Quarantine != "" {
+		quarantineConfig := finder.QuarantineConfig{
+			Root:    cli.InputDir,
+			Dir:     cli.Quarantine,
+			Journal: journal,
+			Algo:    globals.Algo,
+			Verbose: globals.Verbose,
+		}
+		records, err := finder.QuarantineDupes(dupes, quarantineConfig)
+		if err != nil {
+			return err
+		}
+		log.Printf("Moved %v files into quarantine dir %v\n", len(records), cli.Quarantine)
+		return nil
+	}
+
+	linkConfig := finder.LinkConfig{
+		Mode:             cli.Link,
+		RelativeSymlinks: cli.Symlinks == "relative",
+		Journal:          journal,
+		Algo:             globals.Algo,
+		Verbose:          globals.Verbose,
+	}
+	results, err := finder.LinkDupes(dupes, linkConfig)
+	if err != nil {
+		return err
+	}
+	var numLinked int
+	for _, result := range results {
+		if result.Err != nil {
+			log.Printf("WARNING: Could not %v %v to %v: %v\n", cli.Link, result.Entry.File.Path(), result.Kept, result.Err)
+			continue
+		}
+		numLinked += 1
+	}
+	log.Printf("Replaced %v files with a %v to the kept copy\n", numLinked, cli.Link)
+	return nil
+}
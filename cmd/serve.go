@@ -0,0 +1,32 @@
+package main
+
+import (
+	"dupefinder/src" // "dupefinder/src" as finder
+	"fmt"
+	"log"
+	"net/http"
+)
+
+type ServeCmd struct {
+	InputDir  string `help:"path to input dir to search (default: the input-dir setting in the config file)" arg:"" optional:""`
+	LowMemory bool   `help:"search the dir twice so that the files with a unique size are never kept in memory, the same as scan --low-memory"`
+	Listen    string `help:"address to listen on; only local connections are accepted by default since the file paths are shown to anyone who connects" default:"127.0.0.1:8080"`
+}
+
+func (cli *ServeCmd) Run(globals *Globals, loaded *loadedConfig) error {
+	if cli.InputDir == "" {
+		cli.InputDir = loaded.inputDir()
+	}
+	if cli.InputDir == "" {
+		return fmt.Errorf("an input dir is required")
+	}
+	findConfig, hashConfig, err := globals.configs()
+	if err != nil {
+		return err
+	}
+	findConfig.LowMemory = cli.LowMemory
+
+	handler := finder.DupesHandler(cli.InputDir, findConfig, hashConfig, globals.formatConfig())
+	log.Printf("Serving duplicates in %v on http://%v\n", cli.InputDir, cli.Listen)
+	return http.ListenAndServe(cli.Listen, handler)
+}
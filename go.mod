@@ -3,8 +3,12 @@ module dupefinder
 go 1.17
 
 require (
-	github.com/alecthomas/kong v0.5.0 // indirect
-	github.com/cespare/xxhash v1.1.0 // indirect
-	github.com/google/go-cmp v0.5.8 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
+	github.com/BurntSushi/toml v1.2.1
+	github.com/alecthomas/kong v0.5.0
+	github.com/cespare/xxhash v1.1.0
+	github.com/google/go-cmp v0.5.8
+	golang.org/x/crypto v0.1.0
+	golang.org/x/sys v0.1.0
 )
+
+require github.com/pkg/errors v0.9.1 // indirect
@@ -0,0 +1,236 @@
+package finder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// name of the manifest file written inside the quarantine dir
+const QuarantineManifestName = "dupefinder-quarantine.tsv"
+
+type QuarantineConfig struct {
+	Root     string   // dir that was searched; quarantined files keep their path relative to this dir
+	Dir      string   // dir to move the redundant copies into; should be on the same volume as Root
+	Manifest string   // path of the manifest to append to; defaults to QuarantineManifestName inside Dir
+	Journal  *Journal // optional journal to record the moves in so they can be undone
+	Algo     string   // hashing algorithm that was used for the duplicates, recorded in the journal
+	Verbose  bool
+}
+
+// record of a single file that was moved into quarantine
+type QuarantineRecord struct {
+	Hash        string
+	Size        int64
+	Original    string // where the file used to be
+	Quarantined string // where the file is now
+	Kept        string // the copy of the file that was left in place
+	Time        time.Time
+}
+
+// pick the copy to keep from a group of duplicates; the rest are redundant
+// the file with the lexically first path is kept so that repeated runs make the same choice
+func SplitKeeper(entries []FileHashEntry) (FileHashEntry, []FileHashEntry) {
+	sorted := make([]FileHashEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].File.Path() < sorted[j].File.Path() })
+	return sorted[0], sorted[1:]
+}
+
+// compare two files byte for byte, for checking copies that were only matched by a sampled, normalized, or decompressed hash
+func sameContents(pathA string, pathB string) (bool, error) {
+	fileA, err := os.Open(pathA)
+	if err != nil {
+		return false, err
+	}
+	defer fileA.Close()
+	fileB, err := os.Open(pathB)
+	if err != nil {
+		return false, err
+	}
+	defer fileB.Close()
+
+	bufA := make([]byte, 64*1024)
+	bufB := make([]byte, 64*1024)
+	for {
+		nA, errA := io.ReadFull(fileA, bufA)
+		nB, errB := io.ReadFull(fileB, bufB)
+		if !bytes.Equal(bufA[:nA], bufB[:nB]) {
+			return false, nil
+		}
+		// a short read means the end of the file
+		doneA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		doneB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !doneA {
+			return false, errA
+		}
+		if errB != nil && !doneB {
+			return false, errB
+		}
+		if doneA || doneB {
+			return doneA && doneB, nil
+		}
+	}
+}
+
+// get the hashes of the duplicate groups in sorted order so that actions run in a repeatable order
+func sortedDupeHashes(dupes map[HashSum][]FileHashEntry) []HashSum {
+	hashes := []HashSum{}
+	for hash, entries := range dupes {
+		if len(entries) > 1 {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i].Less(hashes[j]) })
+	return hashes
+}
+
+// get the path that a file will be moved to in order to keep the same relative path under the new dir
+func relocatePath(path string, root string, dir string) (string, error) {
+	// paths from a file list can be absolute even when the root is not
+	if filepath.IsAbs(path) != filepath.IsAbs(root) {
+		var err error
+		if path, err = filepath.Abs(path); err != nil {
+			return "", err
+		}
+		if root, err = filepath.Abs(root); err != nil {
+			return "", err
+		}
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %v is not inside of %v", path, root)
+	}
+	return filepath.Join(dir, rel), nil
+}
+
+// move a single file into the quarantine dir, keeping its path relative to the root
+func QuarantineFile(path string, root string, dir string) (string, error) {
+	dest, err := relocatePath(path, root, dir)
+	if err != nil {
+		return "", err
+	}
+
+	// never clobber something that is already in quarantine
+	if _, err := os.Lstat(dest); err == nil {
+		return "", fmt.Errorf("quarantine path already exists: %v", dest)
+	}
+
+	err = os.MkdirAll(filepath.Dir(dest), os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.Rename(path, dest)
+	if err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// move the redundant copies from every duplicate group into the quarantine dir and append them to the manifest
+// files that cannot be moved are skipped with a warning
+func QuarantineDupes(dupes map[HashSum][]FileHashEntry, config QuarantineConfig) ([]QuarantineRecord, error) {
+	records := []QuarantineRecord{}
+
+	err := os.MkdirAll(config.Dir, os.ModePerm)
+	if err != nil {
+		return records, err
+	}
+
+	quarantineDir, err := filepath.Abs(config.Dir)
+	if err != nil {
+		return records, err
+	}
+
+	for _, hash := range sortedDupeHashes(dupes) {
+		keep, redundant := SplitKeeper(dupes[hash])
+		// a copy that is already in quarantine is not a safe copy to keep, since the quarantine may get cleared
+		if inDir(keep.File.Path(), quarantineDir) {
+			logger.Printf("WARNING: Skipping duplicates of %v since the copy to keep is inside the quarantine dir\n", keep.File.Path())
+			continue
+		}
+		for _, entry := range redundant {
+			dest, err := relocatePath(entry.File.Path(), config.Root, config.Dir)
+			if err != nil {
+				logger.Printf("WARNING: Skipping file that could not be quarantined: %v\n", err)
+				continue
+			}
+			journalEntry, err := NewJournalEntry(ActionMove, entry, dest, config.Algo)
+			if err != nil {
+				logger.Printf("WARNING: Skipping file that could not be quarantined: %v\n", err)
+				continue
+			}
+			err = config.Journal.record(journalEntry)
+			if err != nil {
+				return records, err
+			}
+			dest, err = QuarantineFile(entry.File.Path(), config.Root, config.Dir)
+			if err != nil {
+				logger.Printf("WARNING: Skipping file that could not be quarantined: %v\n", err)
+				continue
+			}
+			if config.Verbose {
+				logger.Printf("Quarantined %v to %v\n", entry.File.Path(), dest)
+			}
+			record := QuarantineRecord{
+				Hash:        hash.String(),
+				Size:        entry.File.Size,
+				Original:    entry.File.Path(),
+				Quarantined: dest,
+				Kept:        keep.File.Path(),
+				Time:        time.Now(),
+			}
+			records = append(records, record)
+		}
+	}
+
+	manifest := config.Manifest
+	if manifest == "" {
+		manifest = filepath.Join(config.Dir, QuarantineManifestName)
+	}
+	err = WriteQuarantineManifest(manifest, records)
+	return records, err
+}
+
+// append records to the tab separated quarantine manifest, writing the header if the file is new
+func WriteQuarantineManifest(path string, records []QuarantineRecord) error {
+	_, statErr := os.Stat(path)
+	newFile := os.IsNotExist(statErr)
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if newFile {
+		_, err = file.WriteString("time\thash\tsize\toriginal\tquarantined\tkept\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, record := range records {
+		line := record.Time.Format(time.RFC3339) + "\t" +
+			record.Hash + "\t" +
+			strconv.FormatInt(record.Size, 10) + "\t" +
+			record.Original + "\t" +
+			record.Quarantined + "\t" +
+			record.Kept + "\n"
+		_, err = file.WriteString(line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
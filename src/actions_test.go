@@ -0,0 +1,680 @@
+package finder
+
+import (
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// test for moving duplicate files into quarantine
+func TestQuarantineDupes(t *testing.T) {
+	tempdir := t.TempDir()
+	quarantineDir := t.TempDir()
+
+	subdir1 := createSubDir(tempdir, "subdir.1")
+	subdir2 := createSubDir(tempdir, "subdir.2")
+	tempfile1, _ := createTempFile(subdir1, "f1.", "foo\n")
+	tempfile1.Close()
+	tempfile2, basename2 := createTempFile(subdir2, "f2.", "foo\n")
+	tempfile2.Close()
+	tempfile3, _ := createTempFile(subdir2, "f3.", "bar\n")
+	tempfile3.Close()
+
+	hashConfig := HashConfig{}
+	dupes, _ := FindDupes(tempdir, FindConfig{}, hashConfig)
+	config := QuarantineConfig{Root: tempdir, Dir: quarantineDir}
+	records, err := QuarantineDupes(dupes, config)
+	if err != nil {
+		t.Errorf("got error %v", err)
+	}
+
+	t.Run("Move the redundant copy and keep its relative path", func(t *testing.T) {
+		wantPath := filepath.Join(quarantineDir, "subdir.2", basename2)
+		if len(records) != 1 {
+			t.Fatalf("got %v records, wanted 1: %v", len(records), records)
+		}
+		if diff := cmp.Diff(wantPath, records[0].Quarantined); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(tempfile1.Name(), records[0].Kept); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+		if _, err := os.Stat(tempfile2.Name()); !os.IsNotExist(err) {
+			t.Errorf("file %v should have been moved", tempfile2.Name())
+		}
+		if _, err := os.Stat(wantPath); err != nil {
+			t.Errorf("file %v should exist: %v", wantPath, err)
+		}
+		// files without duplicates stay where they are
+		if _, err := os.Stat(tempfile3.Name()); err != nil {
+			t.Errorf("file %v should exist: %v", tempfile3.Name(), err)
+		}
+	})
+
+	t.Run("Write the manifest", func(t *testing.T) {
+		contents, err := os.ReadFile(filepath.Join(quarantineDir, QuarantineManifestName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("got %v lines in manifest, wanted 2: %v", len(lines), lines)
+		}
+		if !strings.HasSuffix(lines[1], tempfile2.Name()+"\t"+records[0].Quarantined+"\t"+tempfile1.Name()) {
+			t.Errorf("unexpected manifest line %q", lines[1])
+		}
+	})
+}
+
+// test for running quarantine again on a tree that holds the quarantine dir
+func TestQuarantineTwice(t *testing.T) {
+	tempdir := t.TempDir()
+	quarantineDir := filepath.Join(createSubDir(tempdir, "hold"), "q")
+	for _, name := range []string{"z1", "z2"} {
+		os.WriteFile(filepath.Join(tempdir, name), []byte("foo\n"), 0644)
+	}
+	findConfig := FindConfig{SkipPaths: []string{quarantineDir}}
+	config := QuarantineConfig{Root: tempdir, Dir: quarantineDir}
+	dupes, _ := FindDupes(tempdir, findConfig, HashConfig{})
+	if records, err := QuarantineDupes(dupes, config); err != nil || len(records) != 1 {
+		t.Fatalf("got %v, error %v", records, err)
+	}
+
+	t.Run("Skip the quarantine dir by its absolute path", func(t *testing.T) {
+		dupes, _ := FindDupes(tempdir, findConfig, HashConfig{})
+		if len(dupes) != 0 {
+			t.Errorf("got dupes %v, the quarantined copy should not be found", dupes)
+		}
+	})
+
+	t.Run("Never keep a copy that is in quarantine", func(t *testing.T) {
+		// the quarantined copy sorts first so it would be the one to keep
+		dupes, _ := FindDupes(tempdir, FindConfig{}, HashConfig{})
+		records, err := QuarantineDupes(dupes, config)
+		if err != nil || len(records) != 0 {
+			t.Fatalf("got %v, error %v", records, err)
+		}
+		if _, err := os.Stat(filepath.Join(tempdir, "z1")); err != nil {
+			t.Errorf("the last copy outside of quarantine should be left in place: %v", err)
+		}
+	})
+}
+
+// test for undoing actions that were recorded in the journal
+func TestUndoJournal(t *testing.T) {
+	tempdir := t.TempDir()
+	quarantineDir := t.TempDir()
+	journalPath := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	subdir1 := createSubDir(tempdir, "subdir.1")
+	subdir2 := createSubDir(tempdir, "subdir.2")
+	tempfile1, _ := createTempFile(subdir1, "f1.", "foo\n")
+	tempfile1.Close()
+	tempfile2, _ := createTempFile(subdir2, "f2.", "foo\n")
+	tempfile2.Close()
+	tempfile3, _ := createTempFile(subdir2, "f3.", "foo\n")
+	tempfile3.Close()
+
+	t.Run("Move quarantined files back", func(t *testing.T) {
+		journal, err := OpenJournal(journalPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dupes, _ := FindDupes(tempdir, FindConfig{}, HashConfig{})
+		config := QuarantineConfig{Root: tempdir, Dir: quarantineDir, Journal: journal}
+		records, _ := QuarantineDupes(dupes, config)
+		journal.Close()
+
+		entries, err := ReadJournal(journalPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(records) || len(entries) != 2 {
+			t.Fatalf("got %v journal entries, wanted %v", len(entries), len(records))
+		}
+		if entries[0].Action != ActionMove || entries[0].Size != 4 || entries[0].Algo != "md5" {
+			t.Errorf("unexpected journal entry %+v", entries[0])
+		}
+
+		undone, err := UndoJournal(journalPath, UndoConfig{})
+		if err != nil {
+			t.Errorf("got error %v", err)
+		}
+		if len(undone) != 2 {
+			t.Errorf("got %v undone entries, wanted 2", len(undone))
+		}
+		for _, path := range []string{tempfile2.Name(), tempfile3.Name()} {
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("file %v should have been restored: %v", path, err)
+			}
+		}
+	})
+
+	t.Run("Restore linked and deleted files as copies of the kept file", func(t *testing.T) {
+		// replace one duplicate with a hardlink and delete the other
+		hashEntry2 := NewFileHashEntry(NewFileEntryFromPath(tempfile2.Name()), HashConfig{})
+		linkEntry, _ := NewJournalEntry(ActionHardlink, hashEntry2, tempfile1.Name(), "md5")
+		os.Remove(tempfile2.Name())
+		os.Link(tempfile1.Name(), tempfile2.Name())
+
+		hashEntry3 := NewFileHashEntry(NewFileEntryFromPath(tempfile3.Name()), HashConfig{})
+		deleteEntry, _ := NewJournalEntry(ActionDelete, hashEntry3, tempfile1.Name(), "md5")
+		os.Remove(tempfile3.Name())
+
+		for _, entry := range []JournalEntry{linkEntry, deleteEntry} {
+			if err := UndoEntry(entry); err != nil {
+				t.Errorf("got error %v", err)
+			}
+		}
+
+		// the restored files should no longer share storage with the kept file
+		info1, _ := os.Stat(tempfile1.Name())
+		info2, _ := os.Stat(tempfile2.Name())
+		if os.SameFile(info1, info2) {
+			t.Errorf("file %v should not be a hardlink anymore", tempfile2.Name())
+		}
+		got, err := os.ReadFile(tempfile3.Name())
+		if err != nil {
+			t.Errorf("file %v should have been restored: %v", tempfile3.Name(), err)
+		}
+		if diff := cmp.Diff("foo\n", string(got)); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Leave files that changed since they were linked", func(t *testing.T) {
+		// the hardlink was replaced with a new file, and the reflinked file was edited
+		hashEntry2 := NewFileHashEntry(NewFileEntryFromPath(tempfile2.Name()), HashConfig{})
+		linkEntry, _ := NewJournalEntry(ActionHardlink, hashEntry2, tempfile1.Name(), "md5")
+		os.WriteFile(tempfile2.Name(), []byte("new\n"), 0644)
+
+		hashEntry3 := NewFileHashEntry(NewFileEntryFromPath(tempfile3.Name()), HashConfig{})
+		reflinkEntry, _ := NewJournalEntry(ActionReflink, hashEntry3, tempfile1.Name(), "md5")
+		os.WriteFile(tempfile3.Name(), []byte("bar\n"), 0644)
+
+		for _, entry := range []JournalEntry{linkEntry, reflinkEntry} {
+			if err := UndoEntry(entry); err == nil {
+				t.Errorf("expected an error undoing %v of %v", entry.Action, entry.Path)
+			}
+		}
+		for path, want := range map[string]string{tempfile2.Name(): "new\n", tempfile3.Name(): "bar\n"} {
+			got, _ := os.ReadFile(path)
+			if diff := cmp.Diff(want, string(got)); diff != "" {
+				t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+			}
+		}
+	})
+}
+
+// test for replacing duplicate files with links to the kept copy
+func TestLinkDupes(t *testing.T) {
+	tests := map[string]struct {
+		mode string
+	}{
+		"hardlink": {mode: LinkHardlink},
+		"reflink":  {mode: LinkReflink},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tempdir := t.TempDir()
+			tempfile1, _ := createTempFile(tempdir, "f1.", "foo\n")
+			tempfile1.Close()
+			tempfile2, _ := createTempFile(tempdir, "f2.", "foo\n")
+			tempfile2.Close()
+
+			dupes, _ := FindDupes(tempdir, FindConfig{}, HashConfig{})
+			results, err := LinkDupes(dupes, LinkConfig{Mode: tc.mode})
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("got %v results, wanted 1", len(results))
+			}
+			// reflinks need btrfs, XFS, or similar; a loopback mounted image can be used to run this
+			if errors.Is(results[0].Err, ErrReflinkUnsupported) {
+				t.Skipf("skipping on unsupported filesystem: %v", results[0].Err)
+			}
+			if results[0].Err != nil {
+				t.Fatalf("got error %v", results[0].Err)
+			}
+
+			info1, _ := os.Stat(tempfile1.Name())
+			info2, _ := os.Stat(tempfile2.Name())
+			if tc.mode == LinkHardlink && !os.SameFile(info1, info2) {
+				t.Errorf("file %v should be a hardlink to %v", tempfile2.Name(), tempfile1.Name())
+			}
+			if tc.mode == LinkReflink && os.SameFile(info1, info2) {
+				t.Errorf("file %v should still be an independent file", tempfile2.Name())
+			}
+			got, _ := os.ReadFile(tempfile2.Name())
+			if diff := cmp.Diff("foo\n", string(got)); diff != "" {
+				t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// test that files which only have the same hash are never replaced
+func TestLinkHashCollision(t *testing.T) {
+	tempdir := t.TempDir()
+	tempfile1, _ := createTempFile(tempdir, "f1.", "foo\n")
+	tempfile1.Close()
+	tempfile2, _ := createTempFile(tempdir, "f2.", "bar\n")
+	tempfile2.Close()
+
+	// pretend that a weak hash matched both files
+	hash := NewHashSum([]byte{1, 2, 3, 4})
+	dupes := map[HashSum][]FileHashEntry{hash: {
+		{File: NewFileEntryFromPath(tempfile1.Name()), Hash: hash},
+		{File: NewFileEntryFromPath(tempfile2.Name()), Hash: hash},
+	}}
+	results, err := LinkDupes(dupes, LinkConfig{Mode: LinkHardlink})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("expected an error linking files with different contents, got %+v", results)
+	}
+	got, _ := os.ReadFile(tempfile2.Name())
+	if diff := cmp.Diff("bar\n", string(got)); diff != "" {
+		t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// test for replacing duplicate files with symlinks to the kept copy
+func TestSymlinkDupes(t *testing.T) {
+	tempdir := t.TempDir()
+	subdirA := createSubDir(tempdir, "a")
+	subdirB := createSubDir(tempdir, "b")
+	subdirC := createSubDir(tempdir, "c")
+	for path, contents := range map[string]string{
+		filepath.Join(subdirA, "1"): "foo\n",
+		filepath.Join(subdirB, "1"): "foo\n",
+		filepath.Join(subdirB, "2"): "bar\n",
+		filepath.Join(subdirC, "2"): "bar\n",
+	} {
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dupes, _ := FindDupes(tempdir, FindConfig{}, HashConfig{})
+	results, err := LinkDupes(dupes, LinkConfig{Mode: LinkSymlink, RelativeSymlinks: true})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	t.Run("Make relative symlinks to the kept copy", func(t *testing.T) {
+		got, err := os.Readlink(filepath.Join(subdirB, "1"))
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		want := filepath.Join("..", "a", "1")
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Refuse to link into a dir that is also being modified", func(t *testing.T) {
+		var numRefused int
+		for _, result := range results {
+			if result.Err != nil {
+				numRefused += 1
+				if result.Entry.File.Path() != filepath.Join(subdirC, "2") {
+					t.Errorf("unexpected refused file %v", result.Entry.File.Path())
+				}
+			}
+		}
+		if numRefused != 1 {
+			t.Errorf("got %v refused files, wanted 1: %v", numRefused, results)
+		}
+		info, err := os.Lstat(filepath.Join(subdirC, "2"))
+		if err != nil || !info.Mode().IsRegular() {
+			t.Errorf("file %v should not have been replaced", filepath.Join(subdirC, "2"))
+		}
+	})
+
+	t.Run("Make absolute symlinks to the kept copy", func(t *testing.T) {
+		path := filepath.Join(subdirC, "2")
+		err := LinkFile(filepath.Join(subdirB, "2"), path, LinkConfig{Mode: LinkSymlink})
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		got, _ := os.Readlink(path)
+		if diff := cmp.Diff(filepath.Join(subdirB, "2"), got); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+// test for deciding whether a symlink would point into a dir that is being modified
+func TestUnsafeSymlink(t *testing.T) {
+	root := t.TempDir()
+	unsafeDirs := map[string]bool{filepath.Join(root, "a"): true}
+	tests := map[string]struct {
+		keptDir string
+		path    string
+		want    bool
+	}{
+		"Kept in an unsafe dir":             {keptDir: filepath.Join(root, "a"), path: filepath.Join(root, "b", "1"), want: true},
+		"Kept below an unsafe dir":          {keptDir: filepath.Join(root, "a", "sub"), path: filepath.Join(root, "b", "1"), want: true},
+		"Kept next to the link":             {keptDir: filepath.Join(root, "a", "sub"), path: filepath.Join(root, "a", "sub", "1"), want: false},
+		"Kept in a dir with a similar name": {keptDir: filepath.Join(root, "ab"), path: filepath.Join(root, "b", "1"), want: false},
+		"Kept above an unsafe dir":          {keptDir: root, path: filepath.Join(root, "b", "1"), want: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := unsafeSymlink(unsafeDirs, tc.keptDir, tc.path)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// test for choosing which copies to keep interactively
+func TestInteractiveDupes(t *testing.T) {
+	tempdir := t.TempDir()
+	subdirA := createSubDir(tempdir, "a")
+	subdirB := createSubDir(tempdir, "b")
+	subdirC := createSubDir(tempdir, "c")
+	for path, contents := range map[string]string{
+		filepath.Join(subdirA, "1"): "foo\n",
+		filepath.Join(subdirB, "1"): "foo\n",
+		filepath.Join(subdirA, "2"): "bar\n",
+		filepath.Join(subdirB, "2"): "bar\n",
+		filepath.Join(subdirC, "3"): "baz\n",
+		filepath.Join(subdirC, "4"): "baz\n",
+	} {
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dupes, _ := FindDupes(tempdir, FindConfig{}, HashConfig{})
+	// the groups are shown in order of their hash; baz, bar, foo
+	input := strings.NewReader("x\n?\nl1\n2a\n")
+	output := new(strings.Builder)
+	config := InteractiveConfig{In: input, Out: output}
+	got, err := InteractiveDupes(dupes, config)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	want := InteractiveSummary{Deleted: 2, Linked: 1}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("got vs want mismatch (-want +got):\n%s\n%s", diff, output)
+	}
+	for _, path := range []string{filepath.Join(subdirA, "1"), filepath.Join(subdirA, "2")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("file %v should have been deleted", path)
+		}
+	}
+	info3, _ := os.Stat(filepath.Join(subdirC, "3"))
+	info4, _ := os.Stat(filepath.Join(subdirC, "4"))
+	if !os.SameFile(info3, info4) {
+		t.Errorf("file %v should be a hardlink", filepath.Join(subdirC, "4"))
+	}
+	if !strings.Contains(output.String(), "invalid choice") || !strings.Contains(output.String(), "choices:") {
+		t.Errorf("expected invalid choice and help messages in output:\n%s", output)
+	}
+}
+
+// test cases for the checks made before a copy gets deleted or linked interactively
+func TestInteractiveDupesSafeguards(t *testing.T) {
+	t.Run("Leave copies that only match on the sampled bytes", func(t *testing.T) {
+		tempdir := t.TempDir()
+		os.WriteFile(filepath.Join(tempdir, "x"), []byte("AAAAAAAAAAXXXX"), 0644)
+		os.WriteFile(filepath.Join(tempdir, "y"), []byte("AAAAAAAAAAYYYY"), 0644)
+		dupes, _ := FindDupes(tempdir, FindConfig{}, HashConfig{Partial: true, NumBytes: 5})
+		output := new(strings.Builder)
+		got, err := InteractiveDupes(dupes, InteractiveConfig{In: strings.NewReader("1\n"), Out: output})
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if diff := cmp.Diff(InteractiveSummary{Errors: 1}, got); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s\n%s", diff, output)
+		}
+		if contents, _ := os.ReadFile(filepath.Join(tempdir, "y")); string(contents) != "AAAAAAAAAAYYYY" {
+			t.Errorf("got contents %q, the file should have been left alone", contents)
+		}
+		if !strings.Contains(output.String(), "contents differ") {
+			t.Errorf("expected the reason in the output:\n%s", output)
+		}
+	})
+
+	t.Run("Refuse to symlink into a dir that had copies replaced", func(t *testing.T) {
+		tempdir := t.TempDir()
+		subdirA := createSubDir(tempdir, "a")
+		subdirB := createSubDir(tempdir, "b")
+		os.WriteFile(filepath.Join(subdirA, "1"), []byte("foo\n"), 0644)
+		os.WriteFile(filepath.Join(subdirB, "1"), []byte("foo\n"), 0644)
+		os.WriteFile(filepath.Join(subdirA, "2"), []byte("bar\n"), 0644)
+		os.WriteFile(filepath.Join(subdirB, "2"), []byte("bar\n"), 0644)
+		dupes, _ := FindDupes(tempdir, FindConfig{}, HashConfig{})
+		// bar is shown first; a/2 becomes a symlink into b, then b/1 cannot be replaced with a symlink into a
+		output := new(strings.Builder)
+		config := InteractiveConfig{In: strings.NewReader("l2\nl1\n"), Out: output, LinkMode: LinkSymlink}
+		got, err := InteractiveDupes(dupes, config)
+		if err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if diff := cmp.Diff(InteractiveSummary{Linked: 1, Errors: 1}, got); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s\n%s", diff, output)
+		}
+		if info, err := os.Lstat(filepath.Join(subdirB, "1")); err != nil || info.Mode()&os.ModeSymlink != 0 {
+			t.Errorf("file %v should have been left alone", filepath.Join(subdirB, "1"))
+		}
+	})
+}
+
+// test for finding and cleaning up empty files, empty dirs, and broken symlinks
+func TestJunk(t *testing.T) {
+	tempdir := t.TempDir()
+	journalPath := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	emptyFile := filepath.Join(createSubDir(tempdir, "files"), "empty.txt")
+	os.WriteFile(emptyFile, []byte{}, 0600)
+	os.WriteFile(filepath.Join(tempdir, "files", "full.txt"), []byte("foo\n"), 0644)
+	emptyDir := createSubDir(tempdir, "empty")
+	emptySubDir := createSubDir(emptyDir, "sub")
+	brokenLink := filepath.Join(tempdir, "files", "broken")
+	os.Symlink("missing.txt", brokenLink)
+	os.Symlink("full.txt", filepath.Join(tempdir, "files", "working"))
+	// a dir that only holds junk still holds something
+	createSubDir(tempdir, "links")
+	os.Symlink("missing.txt", filepath.Join(tempdir, "links", "broken"))
+
+	report, err := FindJunk(tempdir, FindConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "empty-file\t" + emptyFile + "\n" +
+		"empty-dir\t" + emptyDir + "\n" +
+		"empty-dir\t" + emptySubDir + "\n" +
+		"broken-symlink\t" + brokenLink + "\tmissing.txt\n" +
+		"broken-symlink\t" + filepath.Join(tempdir, "links", "broken") + "\tmissing.txt\n"
+	if diff := cmp.Diff(want, JunkFormatter(report)); diff != "" {
+		t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+	}
+
+	t.Run("Empty files are not duplicates when skipped", func(t *testing.T) {
+		_, numFiles := FindFilesSizes(tempdir, FindConfig{SkipEmpty: true})
+		if numFiles != 1 {
+			t.Errorf("got %v files, wanted 1", numFiles)
+		}
+	})
+
+	t.Run("Clean up the junk and undo it", func(t *testing.T) {
+		journal, err := OpenJournal(journalPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		removed, err := CleanJunk(report, JunkCleanConfig{Journal: journal})
+		journal.Close()
+		if err != nil || len(removed) != 5 {
+			t.Errorf("got %v removed, error %v", removed, err)
+		}
+		for _, path := range []string{emptyFile, emptyDir, brokenLink} {
+			if _, err := os.Lstat(path); !os.IsNotExist(err) {
+				t.Errorf("%v should have been removed", path)
+			}
+		}
+
+		undone, err := UndoJournal(journalPath, UndoConfig{})
+		if err != nil || len(undone) != 5 {
+			t.Errorf("got %v undone, error %v", len(undone), err)
+		}
+		restored, _ := FindJunk(tempdir, FindConfig{})
+		if diff := cmp.Diff(want, JunkFormatter(restored)); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+		info, err := os.Stat(emptyFile)
+		if err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("got %v, error %v", info, err)
+		}
+	})
+}
+
+// test for merging several dirs into one without storing the same contents twice
+func TestConsolidate(t *testing.T) {
+	tempdir := t.TempDir()
+	dest := createSubDir(tempdir, "archive")
+	laptop1 := createSubDir(tempdir, "laptop1")
+	laptop2 := createSubDir(tempdir, "laptop2")
+	createSubDir(laptop1, "docs")
+	createSubDir(laptop2, "docs")
+	files := map[string]string{
+		"archive/old.txt":        "old\n",
+		"laptop1/docs/notes.txt": "notes v1\n",
+		"laptop1/docs/copy.txt":  "notes v1\n",
+		"laptop1/old.txt":        "old\n",
+		"laptop2/docs/notes.txt": "notes v2\n", // same path, different contents
+		"laptop2/photo.jpg":      "photo\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(tempdir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	modTime := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	os.Chtimes(filepath.Join(laptop2, "photo.jpg"), modTime, modTime)
+
+	records, err := Consolidate([]string{laptop1, laptop2}, FindConfig{}, HashConfig{}, ConsolidateConfig{Dest: dest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, record := range records {
+		got = append(got, record.Status+" "+record.Source+" "+record.Stored)
+	}
+	want := []string{
+		"copied " + filepath.Join(laptop1, "docs", "copy.txt") + " " + filepath.Join(dest, "docs", "copy.txt"),
+		"duplicate " + filepath.Join(laptop1, "docs", "notes.txt") + " " + filepath.Join(dest, "docs", "copy.txt"),
+		"existing " + filepath.Join(laptop1, "old.txt") + " " + filepath.Join(dest, "old.txt"),
+		"copied " + filepath.Join(laptop2, "docs", "notes.txt") + " " + filepath.Join(dest, "docs", "notes.txt"),
+		"copied " + filepath.Join(laptop2, "photo.jpg") + " " + filepath.Join(dest, "photo.jpg"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+	}
+
+	t.Run("Keep the modification time", func(t *testing.T) {
+		info, err := os.Stat(filepath.Join(dest, "photo.jpg"))
+		if err != nil || !info.ModTime().Equal(modTime) {
+			t.Errorf("got %v, error %v", info, err)
+		}
+	})
+
+	t.Run("Running again stores nothing new", func(t *testing.T) {
+		records, err := Consolidate([]string{laptop1, laptop2}, FindConfig{}, HashConfig{}, ConsolidateConfig{Dest: dest})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range records {
+			if record.Status != ConsolidateExisting {
+				t.Errorf("got record %+v", record)
+			}
+		}
+		contents, _ := os.ReadFile(filepath.Join(dest, ConsolidateManifestName))
+		if lines := strings.Split(strings.TrimSpace(string(contents)), "\n"); len(lines) != 11 {
+			t.Errorf("got %v lines in manifest, wanted 11: %v", len(lines), lines)
+		}
+	})
+
+	t.Run("Files at a path that is taken get a new name", func(t *testing.T) {
+		laptop3 := createSubDir(tempdir, "laptop3")
+		createSubDir(laptop3, "docs")
+		os.WriteFile(filepath.Join(laptop3, "docs", "notes.txt"), []byte("notes v3\n"), 0644)
+		journalPath := filepath.Join(tempdir, "journal.jsonl")
+		journal, _ := OpenJournal(journalPath)
+		records, err := Consolidate([]string{laptop3}, FindConfig{}, HashConfig{}, ConsolidateConfig{Dest: dest, Move: true, Journal: journal})
+		journal.Close()
+		if err != nil || len(records) != 1 {
+			t.Fatalf("got %v, error %v", records, err)
+		}
+		if records[0].Status != ConsolidateMoved || records[0].Stored != filepath.Join(dest, "docs", "notes (2).txt") {
+			t.Errorf("got record %+v", records[0])
+		}
+		if _, err := UndoJournal(journalPath, UndoConfig{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(laptop3, "docs", "notes.txt")); err != nil {
+			t.Errorf("moved file should have been put back: %v", err)
+		}
+	})
+
+	t.Run("Files are compared by their whole contents", func(t *testing.T) {
+		laptop5 := createSubDir(tempdir, "laptop5")
+		os.WriteFile(filepath.Join(laptop5, "a.txt"), []byte("AAAAAAAAAAXXXX"), 0644)
+		os.WriteFile(filepath.Join(laptop5, "b.txt"), []byte("AAAAAAAAAAYYYY"), 0644)
+		hashConfig := HashConfig{Partial: true, NumBytes: 5, NormalizeText: true}
+		records, err := Consolidate([]string{laptop5}, FindConfig{}, hashConfig, ConsolidateConfig{Dest: dest})
+		if err != nil || len(records) != 2 {
+			t.Fatalf("got %v, error %v", records, err)
+		}
+		for _, record := range records {
+			if record.Status != ConsolidateCopied {
+				t.Errorf("got record %+v", record)
+			}
+		}
+	})
+
+	t.Run("Source dirs with the same name as the destination are stored", func(t *testing.T) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chdir(tempdir); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chdir(cwd)
+		createSubDir(createSubDir(tempdir, "laptop4"), "archive")
+		os.WriteFile(filepath.Join(tempdir, "laptop4", "archive", "a.txt"), []byte("archived\n"), 0644)
+
+		records, err := Consolidate([]string{"laptop4"}, FindConfig{}, HashConfig{}, ConsolidateConfig{Dest: "archive"})
+		if err != nil || len(records) != 1 {
+			t.Fatalf("got %v, error %v", records, err)
+		}
+		if records[0].Status != ConsolidateCopied || records[0].Stored != filepath.Join("archive", "archive", "a.txt") {
+			t.Errorf("got record %+v", records[0])
+		}
+	})
+
+	t.Run("Files in a destination inside a source are not stored again", func(t *testing.T) {
+		records, err := Consolidate([]string{tempdir}, FindConfig{}, HashConfig{}, ConsolidateConfig{Dest: dest})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range records {
+			if strings.HasPrefix(record.Source, dest+string(filepath.Separator)) {
+				t.Errorf("got record for a stored file %+v", record)
+			}
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package finder
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"github.com/cespare/xxhash" //https://pkg.go.dev/github.com/cespare/xxhash#section-readme
+	"golang.org/x/crypto/blake2b"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// the algorithm used when none was given in the HashConfig
+const DefaultHashAlgorithm = "md5"
+
+// HashAlgorithm creates a new hash.Hash for each file that gets hashed
+// library users can add their own with RegisterHashAlgorithm
+type HashAlgorithm func() hash.Hash
+
+var (
+	hashAlgorithmsMutex sync.RWMutex
+	hashAlgorithms      = map[string]HashAlgorithm{
+		"md5":    md5.New,
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+		"xxhash": func() hash.Hash { return xxhash.New() },
+		// blake2b only returns an error when given an invalid key, and we do not use a key
+		"blake2b":     func() hash.Hash { h, _ := blake2b.New256(nil); return h },
+		"blake2b-512": func() hash.Hash { h, _ := blake2b.New512(nil); return h },
+		"crc32":       func() hash.Hash { return crc32.NewIEEE() },
+		"crc32c":      func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+		"crc64":       func() hash.Hash { return crc64.New(crc64.MakeTable(crc64.ECMA)) },
+		"fnv64a":      func() hash.Hash { return fnv.New64a() },
+		"fnv128a":     func() hash.Hash { return fnv.New128a() },
+	}
+)
+
+// add a new hashing algorithm that can be selected by name with HashConfig.Algo
+func RegisterHashAlgorithm(name string, algo HashAlgorithm) error {
+	if name == "" {
+		return fmt.Errorf("hash algorithm name must not be empty")
+	}
+	if algo == nil {
+		return fmt.Errorf("hash algorithm %q must not be nil", name)
+	}
+
+	hashAlgorithmsMutex.Lock()
+	defer hashAlgorithmsMutex.Unlock()
+	if _, ok := hashAlgorithms[name]; ok {
+		return fmt.Errorf("hash algorithm %q is already registered", name)
+	}
+	hashAlgorithms[name] = algo
+	return nil
+}
+
+// look up a hashing algorithm by name; empty name returns the default algorithm
+func GetHashAlgorithm(name string) (HashAlgorithm, error) {
+	if name == "" {
+		name = DefaultHashAlgorithm
+	}
+
+	hashAlgorithmsMutex.RLock()
+	algo, ok := hashAlgorithms[name]
+	hashAlgorithmsMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm %q, valid options are: %s", name, strings.Join(HashAlgorithmNames(), ", "))
+	}
+	return algo, nil
+}
+
+// sorted list of the names of all the registered hashing algorithms
+func HashAlgorithmNames() []string {
+	hashAlgorithmsMutex.RLock()
+	defer hashAlgorithmsMutex.RUnlock()
+	names := []string{}
+	for name := range hashAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,242 @@
+package finder
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// a single line of a checksum manifest
+type ChecksumEntry struct {
+	Hash string
+	Path string
+}
+
+// a file whose contents no longer match the checksum manifest
+type ChecksumMismatch struct {
+	Path string
+	Want string // hash in the manifest
+	Got  string // hash of the file now
+}
+
+// the result of checking the files in a checksum manifest
+type VerifyReport struct {
+	NumOK      int
+	Mismatched []ChecksumMismatch
+	Missing    []string    // files in the manifest that no longer exist
+	Unreadable []string    // files in the manifest that could not be hashed
+	New        []FileEntry // files in the dir that are not in the manifest
+}
+
+// check if any of the files in the manifest failed verification; new files are not counted as failures
+func (report VerifyReport) Failed() bool {
+	return len(report.Mismatched) > 0 || len(report.Missing) > 0 || len(report.Unreadable) > 0
+}
+
+// escape a path the same way as sha256sum so that paths with newlines stay on a single line
+// returns true if the path needed escaping, in which case the line has to start with a backslash
+func escapeChecksumPath(path string) (string, bool) {
+	if !strings.ContainsAny(path, "\\\n\r") {
+		return path, false
+	}
+	replacer := strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r")
+	return replacer.Replace(path), true
+}
+
+func unescapeChecksumPath(path string) (string, error) {
+	var unescaped strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] != '\\' {
+			unescaped.WriteByte(path[i])
+			continue
+		}
+		if i+1 == len(path) {
+			return "", fmt.Errorf("path ends with a backslash")
+		}
+		i++
+		switch path[i] {
+		case '\\':
+			unescaped.WriteByte('\\')
+		case 'n':
+			unescaped.WriteByte('\n')
+		case 'r':
+			unescaped.WriteByte('\r')
+		default:
+			return "", fmt.Errorf("unknown escape sequence \\%c", path[i])
+		}
+	}
+	return unescaped.String(), nil
+}
+
+// write the hashes of all the files to a checksum manifest in the same format as sha256sum and md5sum,
+// sorted by path, so that it can be checked with 'sha256sum -c' as well as VerifyChecksums
+func WriteChecksums(writer io.Writer, hashes map[HashSum][]FileHashEntry) error {
+	entries := []FileHashEntry{}
+	for _, hashEntries := range hashes {
+		entries = append(entries, hashEntries...)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].File.Path() < entries[j].File.Path() })
+
+	bufWriter := bufio.NewWriter(writer)
+	for _, entry := range entries {
+		if entry.Sample != "" || entry.Normalized || entry.Decompressed != "" {
+			return fmt.Errorf("hash of %v is not of the whole file and cannot be used in a checksum manifest", entry.File.Path())
+		}
+		path, escaped := escapeChecksumPath(entry.File.Path())
+		if escaped {
+			bufWriter.WriteString("\\")
+		}
+		if _, err := bufWriter.WriteString(entry.Hash.String() + "  " + path + "\n"); err != nil {
+			return err
+		}
+	}
+	return bufWriter.Flush()
+}
+
+// get the files that HashFiles could not hash, which are left out of a manifest written with WriteChecksums
+// and would be reported as new by VerifyChecksums
+func UnhashedFiles(fileMap map[int64][]FileEntry, hashes map[HashSum][]FileHashEntry) []FileEntry {
+	hashed := hashesByPath(hashes)
+	unhashed := []FileEntry{}
+	for _, entries := range fileMap {
+		for _, entry := range entries {
+			if _, ok := hashed[entry.Path()]; !ok {
+				unhashed = append(unhashed, entry)
+			}
+		}
+	}
+	sortFileEntries(unhashed)
+	return unhashed
+}
+
+// read a checksum manifest in the format written by sha256sum and md5sum
+// both the text ("hash  path") and binary ("hash *path") forms are accepted; blank lines are skipped
+func ReadChecksums(reader io.Reader) ([]ChecksumEntry, error) {
+	entries := []ChecksumEntry{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum += 1
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+		i := strings.Index(line, " ")
+		if i <= 0 || i+2 > len(line) || (line[i+1] != ' ' && line[i+1] != '*') {
+			return entries, fmt.Errorf("line %v is not in the format 'hash  path': %q", lineNum, line)
+		}
+		hash, path := line[:i], line[i+2:]
+		if escaped {
+			var err error
+			if path, err = unescapeChecksumPath(path); err != nil {
+				return entries, fmt.Errorf("line %v: %v", lineNum, err)
+			}
+		}
+		entries = append(entries, ChecksumEntry{Hash: strings.ToLower(hash), Path: path})
+	}
+	return entries, scanner.Err()
+}
+
+// check that the hashes in a manifest are as long as the hashes from the algorithm, since checking them with
+// the wrong algorithm would report every file as changed
+func checkChecksumsAlgo(entries []ChecksumEntry, algoName string) error {
+	if algoName == "" {
+		algoName = DefaultHashAlgorithm
+	}
+	algo, err := GetHashAlgorithm(algoName)
+	if err != nil {
+		return err
+	}
+	wantLen := 2 * algo().Size()
+	for _, entry := range entries {
+		if len(entry.Hash) == wantLen {
+			continue
+		}
+		// suggest the algorithms that make hashes of the same length
+		matching := []string{}
+		for _, name := range HashAlgorithmNames() {
+			if other, err := GetHashAlgorithm(name); err == nil && 2*other().Size() == len(entry.Hash) {
+				matching = append(matching, name)
+			}
+		}
+		err := fmt.Errorf("hash of %v in the manifest has %v characters but %v hashes have %v, so the manifest was written with a different algorithm", entry.Path, len(entry.Hash), algoName, wantLen)
+		if len(matching) > 0 {
+			err = fmt.Errorf("%v (one of: %v)", err, strings.Join(matching, ", "))
+		}
+		return err
+	}
+	return nil
+}
+
+// re-hash the files in a checksum manifest to find the ones that changed or went missing since it was written
+// if dir is not empty it is also searched for files that are not in the manifest; the config is used for
+// the search the same way as FindFilesSizes
+// the hash config needs to use the same algorithm that the manifest was written with; an error is returned
+// without checking any files if the hashes in the manifest are the wrong length for it
+func VerifyChecksums(entries []ChecksumEntry, dir string, findConfig FindConfig, hashConfig HashConfig) (VerifyReport, error) {
+	report := VerifyReport{}
+	if err := checkChecksumsAlgo(entries, hashConfig.Algo); err != nil {
+		return report, err
+	}
+	fileMap := map[int64][]FileEntry{}
+	wantHashes := map[string]string{}
+	for _, entry := range entries {
+		info, err := os.Stat(entry.Path)
+		if os.IsNotExist(err) {
+			report.Missing = append(report.Missing, entry.Path)
+			continue
+		}
+		if err != nil || !info.Mode().IsRegular() {
+			report.Unreadable = append(report.Unreadable, entry.Path)
+			continue
+		}
+		fileMap[info.Size()] = append(fileMap[info.Size()], NewFileEntryFromPathInfo(entry.Path, info))
+		wantHashes[entry.Path] = entry.Hash
+	}
+
+	hashes := hashesByPath(HashFiles(fileMap, hashConfig))
+	for path, want := range wantHashes {
+		hashEntry, ok := hashes[path]
+		switch {
+		case !ok:
+			report.Unreadable = append(report.Unreadable, path)
+		case hashEntry.Hash.String() != want:
+			report.Mismatched = append(report.Mismatched, ChecksumMismatch{Path: path, Want: want, Got: hashEntry.Hash.String()})
+		default:
+			report.NumOK += 1
+		}
+	}
+	sort.Slice(report.Mismatched, func(i, j int) bool { return report.Mismatched[i].Path < report.Mismatched[j].Path })
+	sort.Strings(report.Missing)
+	sort.Strings(report.Unreadable)
+
+	if dir == "" {
+		return report, nil
+	}
+	// the manifest paths and the search paths can be written differently, so compare them as absolute paths
+	known := map[string]bool{}
+	for _, entry := range entries {
+		if path, err := filepath.Abs(entry.Path); err == nil {
+			known[path] = true
+		}
+	}
+	dirMap, _ := FindFilesSizes(dir, findConfig)
+	for _, dirEntries := range dirMap {
+		for _, entry := range dirEntries {
+			if path, err := filepath.Abs(entry.Path()); err == nil && !known[path] {
+				report.New = append(report.New, entry)
+			}
+		}
+	}
+	sortFileEntries(report.New)
+	return report, nil
+}
@@ -0,0 +1,107 @@
+package finder
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// test cases for writing a checksum manifest and verifying the files against it
+func TestChecksums(t *testing.T) {
+	tempdir := t.TempDir()
+	files := map[string]string{
+		"keep.txt":      "foo\n",
+		"rot.txt":       "bar\n",
+		"gone.txt":      "baz\n",
+		"new\nline.txt": "foo\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(tempdir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fileMap, _ := FindFilesSizes(tempdir, FindConfig{})
+	hashConfig := HashConfig{Algo: "sha256", NumWorkers: 2}
+	manifest := bytes.Buffer{}
+	if err := WriteChecksums(&manifest, HashFiles(fileMap, hashConfig)); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Write in the same format as sha256sum", func(t *testing.T) {
+		want := "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c  " + filepath.Join(tempdir, "keep.txt") + "\n"
+		if !strings.Contains(manifest.String(), want) {
+			t.Errorf("manifest does not contain %q:\n%s", want, manifest.String())
+		}
+		// paths with a newline are escaped and the line starts with a backslash
+		want = "\\b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c  " + filepath.Join(tempdir, "new\\nline.txt") + "\n"
+		if !strings.Contains(manifest.String(), want) {
+			t.Errorf("manifest does not contain %q:\n%s", want, manifest.String())
+		}
+	})
+
+	t.Run("Find the files that could not be hashed", func(t *testing.T) {
+		missing := NewFileEntryFromPath(filepath.Join(tempdir, "keep.txt"))
+		missing.Name = "missing.txt"
+		withMissing := map[int64][]FileEntry{4: append(append([]FileEntry{}, fileMap[4]...), missing)}
+		got := UnhashedFiles(withMissing, HashFiles(withMissing, hashConfig))
+		if len(got) != 1 || got[0].Path() != filepath.Join(tempdir, "missing.txt") {
+			t.Errorf("got %+v", got)
+		}
+	})
+
+	entries, err := ReadChecksums(bytes.NewReader(manifest.Bytes()))
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("got %v entries, error %v", entries, err)
+	}
+
+	t.Run("Nothing changed", func(t *testing.T) {
+		report, err := VerifyChecksums(entries, tempdir, FindConfig{}, hashConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.NumOK != 4 || report.Failed() || len(report.New) != 0 {
+			t.Errorf("got report %+v", report)
+		}
+	})
+
+	t.Run("Reject a manifest written with a different algorithm", func(t *testing.T) {
+		_, err := VerifyChecksums(entries, tempdir, FindConfig{}, HashConfig{Algo: "md5"})
+		if err == nil || !strings.Contains(err.Error(), "sha256") {
+			t.Errorf("got error %v, wanted one suggesting sha256", err)
+		}
+	})
+
+	t.Run("Find changed, missing, and new files", func(t *testing.T) {
+		os.WriteFile(filepath.Join(tempdir, "rot.txt"), []byte("bat\n"), 0644)
+		os.Remove(filepath.Join(tempdir, "gone.txt"))
+		os.WriteFile(filepath.Join(tempdir, "added.txt"), []byte("added\n"), 0644)
+		report, err := VerifyChecksums(entries, tempdir, FindConfig{}, hashConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "mismatch\t" + filepath.Join(tempdir, "rot.txt") + "\t" +
+			"7d865e959b2466918c9863afca942d0fb89d7c9ac0c99bafc3749504ded97730\t" +
+			"2b1af0fe3b9b32d6a425f0d4f9b06eade50cff70aaa41824da025576d00bbf47\n" +
+			"missing\t" + filepath.Join(tempdir, "gone.txt") + "\n" +
+			"new\t" + filepath.Join(tempdir, "added.txt") + "\n"
+		if diff := cmp.Diff(want, VerifyFormatter(report)); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+		if report.NumOK != 2 || !report.Failed() {
+			t.Errorf("got report %+v", report)
+		}
+	})
+
+	t.Run("Read the binary mode format and reject bad lines", func(t *testing.T) {
+		entries, err := ReadChecksums(strings.NewReader("ABC123 *some file.bin\n\n"))
+		if diff := cmp.Diff([]ChecksumEntry{{Hash: "abc123", Path: "some file.bin"}}, entries); diff != "" || err != nil {
+			t.Errorf("got vs want mismatch (-want +got), error %v:\n%s", err, diff)
+		}
+		if _, err := ReadChecksums(strings.NewReader("not a checksum\n")); err == nil {
+			t.Errorf("expected an error for a bad line")
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package finder
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// a file in the first dir along with its copies in the second dir
+type CompareMatch struct {
+	A FileHashEntry
+	B []FileHashEntry // sorted by path
+}
+
+// a file that is at the same relative path in both dirs but has different contents
+type ChangedFile struct {
+	RelPath string
+	A       FileEntry
+	B       FileEntry
+}
+
+// the result of comparing the contents of two dir trees
+// files that are listed in Changed are not also listed in OnlyA or OnlyB
+type CompareReport struct {
+	Matches []CompareMatch // files in A that have a copy anywhere in B
+	OnlyA   []FileEntry    // files in A with no copy anywhere in B
+	OnlyB   []FileEntry    // files in B with no copy anywhere in A
+	Changed []ChangedFile
+}
+
+// get the files in a map of files grouped by size, keyed by their path relative to the dir
+func relPathMap(dirPath string, fileMap map[int64][]FileEntry) map[string]FileEntry {
+	relPaths := map[string]FileEntry{}
+	for _, entries := range fileMap {
+		for _, entry := range entries {
+			relPath, err := filepath.Rel(dirPath, entry.Path())
+			if err != nil {
+				relPath = entry.Path()
+			}
+			relPaths[relPath] = entry
+		}
+	}
+	return relPaths
+}
+
+// get the hash of every file in a map of hashes, keyed by path
+func hashesByPath(hashes map[HashSum][]FileHashEntry) map[string]FileHashEntry {
+	paths := map[string]FileHashEntry{}
+	for _, entries := range hashes {
+		for _, entry := range entries {
+			paths[entry.File.Path()] = entry
+		}
+	}
+	return paths
+}
+
+func sortFileEntries(entries []FileEntry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path() < entries[j].Path() })
+}
+
+// compare the contents of two dir trees, such as the source and destination of a copy, to find the files
+// that were copied, the files that are only on one side, and the files that changed at the same path
+// files are only hashed if there is a file of the same size on the other side, the same as FindDupes
+func CompareDirs(dirA string, dirB string, findConfig FindConfig, hashConfig HashConfig) CompareReport {
+	report := CompareReport{}
+	fileMapA, _ := FindFilesSizes(dirA, findConfig)
+	fileMapB, _ := FindFilesSizes(dirB, findConfig)
+
+	// a file can only have a copy on the other side if there is a file with the same size there
+	toHashA := map[int64][]FileEntry{}
+	toHashB := map[int64][]FileEntry{}
+	for size, entries := range fileMapA {
+		if _, ok := fileMapB[size]; ok {
+			toHashA[size] = entries
+			toHashB[size] = fileMapB[size]
+		}
+	}
+	hashesA := HashFiles(toHashA, hashConfig)
+	hashesB := HashFiles(toHashB, hashConfig)
+	pathHashesA := hashesByPath(hashesA)
+	pathHashesB := hashesByPath(hashesB)
+
+	// files at the same path with different sizes or hashes
+	relPathsA := relPathMap(dirA, fileMapA)
+	relPathsB := relPathMap(dirB, fileMapB)
+	changed := map[string]bool{}
+	for relPath, entryA := range relPathsA {
+		entryB, ok := relPathsB[relPath]
+		if !ok {
+			continue
+		}
+		hashA, okA := pathHashesA[entryA.Path()]
+		hashB, okB := pathHashesB[entryB.Path()]
+		// files that could not be hashed are left out rather than reported as changed
+		if entryA.Size == entryB.Size && (!okA || !okB || hashA.Hash == hashB.Hash) {
+			continue
+		}
+		report.Changed = append(report.Changed, ChangedFile{RelPath: relPath, A: entryA, B: entryB})
+		changed[entryA.Path()] = true
+		changed[entryB.Path()] = true
+	}
+	sort.Slice(report.Changed, func(i, j int) bool { return report.Changed[i].RelPath < report.Changed[j].RelPath })
+
+	for _, entries := range fileMapA {
+		for _, entry := range entries {
+			hashEntry, ok := pathHashesA[entry.Path()]
+			if ok && len(hashesB[hashEntry.Hash]) > 0 {
+				copies := append([]FileHashEntry{}, hashesB[hashEntry.Hash]...)
+				sort.Slice(copies, func(i, j int) bool { return copies[i].File.Path() < copies[j].File.Path() })
+				report.Matches = append(report.Matches, CompareMatch{A: hashEntry, B: copies})
+			} else if !changed[entry.Path()] {
+				report.OnlyA = append(report.OnlyA, entry)
+			}
+		}
+	}
+	sort.Slice(report.Matches, func(i, j int) bool { return report.Matches[i].A.File.Path() < report.Matches[j].A.File.Path() })
+	sortFileEntries(report.OnlyA)
+
+	for _, entries := range fileMapB {
+		for _, entry := range entries {
+			hashEntry, ok := pathHashesB[entry.Path()]
+			if (!ok || len(hashesA[hashEntry.Hash]) == 0) && !changed[entry.Path()] {
+				report.OnlyB = append(report.OnlyB, entry)
+			}
+		}
+	}
+	sortFileEntries(report.OnlyB)
+	return report
+}
@@ -0,0 +1,54 @@
+package finder
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// test cases for comparing two dirs
+func TestCompareDirs(t *testing.T) {
+	tempdir := t.TempDir()
+	dirA := createSubDir(tempdir, "src")
+	dirB := createSubDir(tempdir, "dst")
+	createSubDir(dirB, "moved")
+	files := map[string]string{
+		"src/copied.txt":       "foo\n",
+		"dst/copied.txt":       "foo\n",
+		"src/renamed.txt":      "bar bar\n",
+		"dst/moved/other.txt":  "bar bar\n",
+		"src/edited.txt":       "port: 80\n",
+		"dst/edited.txt":       "port: 81\n", // same size, different contents
+		"src/grown.txt":        "baz\n",
+		"dst/grown.txt":        "baz baz\n", // different size
+		"src/missing.txt":      "missing\n",
+		"dst/new.txt":          "new file\n",
+		"dst/moved/copied.txt": "foo\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(tempdir, name), []byte(contents), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	report := CompareDirs(dirA, dirB, FindConfig{}, HashConfig{NumWorkers: 2})
+	got := CompareFormatter(report, FormatConfig{})
+	want := "match\t" + filepath.Join(dirA, "copied.txt") + "\t" + filepath.Join(dirB, "copied.txt") + "\n" +
+		"match\t" + filepath.Join(dirA, "copied.txt") + "\t" + filepath.Join(dirB, "moved", "copied.txt") + "\n" +
+		"match\t" + filepath.Join(dirA, "renamed.txt") + "\t" + filepath.Join(dirB, "moved", "other.txt") + "\n" +
+		"only-a\t" + filepath.Join(dirA, "missing.txt") + "\n" +
+		"only-b\t" + filepath.Join(dirB, "new.txt") + "\n" +
+		"changed\t" + filepath.Join(dirA, "edited.txt") + "\t" + filepath.Join(dirB, "edited.txt") + "\n" +
+		"changed\t" + filepath.Join(dirA, "grown.txt") + "\t" + filepath.Join(dirB, "grown.txt") + "\n"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+	}
+
+	// comparing a dir to itself only finds copies
+	report = CompareDirs(dirA, dirA, FindConfig{}, HashConfig{})
+	if len(report.Matches) != 5 || len(report.OnlyA) != 0 || len(report.OnlyB) != 0 || len(report.Changed) != 0 {
+		t.Errorf("got report %+v", report)
+	}
+}
@@ -0,0 +1,93 @@
+package finder
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// compression formats that can be compared by their decompressed contents
+const (
+	CompressionGzip  = "gzip"
+	CompressionBzip2 = "bzip2"
+)
+
+// get the compression format of a file from its extension, or an empty string if it is not compressed
+func compressionFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz":
+		return CompressionGzip
+	case ".bz2":
+		return CompressionBzip2
+	default:
+		return ""
+	}
+}
+
+// get a reader for the decompressed contents of an open file
+func decompressReader(file io.Reader, format string) (io.Reader, error) {
+	switch format {
+	case CompressionGzip:
+		return gzip.NewReader(file)
+	case CompressionBzip2:
+		return bzip2.NewReader(file), nil
+	default:
+		return nil, fmt.Errorf("unknown compression format %q", format)
+	}
+}
+
+// get the size of the decompressed contents of a file; this has to decompress the whole file
+// since the size stored in a gzip file is only for the last member and wraps at 4GiB
+func decompressedSize(path string, format string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	reader, err := decompressReader(file, format)
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(io.Discard, reader)
+}
+
+// get the hash of the decompressed contents of an open file
+// sampling is not used since the decompressed stream cannot be read from an offset
+func getDecompressedHash(inputFile *os.File, format string, config HashConfig) (HashSum, error) {
+	newHash, err := GetHashAlgorithm(config.Algo)
+	if err != nil {
+		return HashSum{}, err
+	}
+	hashWriter := newHash()
+	reader, err := decompressReader(inputFile, format)
+	if err != nil {
+		return HashSum{}, err
+	}
+	_, err = io.Copy(hashWriter, reader)
+	if err != nil {
+		return HashSum{}, err
+	}
+	return NewHashSum(hashWriter.Sum(nil)), nil
+}
+
+// regroup files by the size of their contents, so that compressed files line up with uncompressed copies
+// compressed files that cannot be decompressed stay grouped by their size on disk
+func GroupByDecompressedSize(fileSizeMap map[int64][]FileEntry) map[int64][]FileEntry {
+	contentSizeMap := map[int64][]FileEntry{}
+	for size, entries := range fileSizeMap {
+		for _, entry := range entries {
+			contentSize := size
+			if format := compressionFormat(entry.Path()); format != "" {
+				if decompressed, err := decompressedSize(entry.Path(), format); err == nil {
+					contentSize = decompressed
+				}
+			}
+			contentSizeMap[contentSize] = append(contentSizeMap[contentSize], entry)
+		}
+	}
+	return contentSizeMap
+}
@@ -0,0 +1,95 @@
+package finder
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io"
+	"sort"
+)
+
+// default place to look for the config file; ~ is expanded to the home dir
+const DefaultConfigPath = "~/.config/dupefinder/config"
+
+// settings from a TOML config file, keyed by the name of the command line flag they set (e.g. "include-ext")
+// settings at the top level apply to every run; settings in a [profiles.<name>] table only apply
+// when that profile is picked, and take precedence over the top level ones
+type Config struct {
+	Settings map[string]interface{}
+	Profiles map[string]map[string]interface{}
+}
+
+// read a TOML config file
+func ReadConfig(reader io.Reader) (Config, error) {
+	config := Config{Settings: map[string]interface{}{}, Profiles: map[string]map[string]interface{}{}}
+	raw := map[string]interface{}{}
+	if _, err := toml.NewDecoder(reader).Decode(&raw); err != nil {
+		return config, err
+	}
+	for key, value := range raw {
+		if key != "profiles" {
+			config.Settings[key] = value
+			continue
+		}
+		profiles, ok := value.(map[string]interface{})
+		if !ok {
+			return config, fmt.Errorf("profiles must be tables, e.g. [profiles.photos]")
+		}
+		for name, settings := range profiles {
+			profile, ok := settings.(map[string]interface{})
+			if !ok {
+				return config, fmt.Errorf("profile %q must be a table, e.g. [profiles.%v]", name, name)
+			}
+			config.Profiles[name] = profile
+		}
+	}
+	return config, nil
+}
+
+// get a setting for the profile, falling back to the top level settings; an empty profile only uses the top level
+func (config Config) Lookup(profile string, name string) (interface{}, bool) {
+	if value, ok := config.Profiles[profile][name]; ok {
+		return value, true
+	}
+	value, ok := config.Settings[name]
+	return value, ok
+}
+
+// sorted list of the names of all the profiles
+func (config Config) ProfileNames() []string {
+	names := []string{}
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// check that every setting in the config is one of the known names, to catch typos that would otherwise be ignored
+func (config Config) Validate(known []string) error {
+	isKnown := map[string]bool{}
+	for _, name := range known {
+		isKnown[name] = true
+	}
+	check := func(settings map[string]interface{}, where string) error {
+		names := []string{}
+		for name := range settings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if !isKnown[name] {
+				return fmt.Errorf("unknown setting %q in %v", name, where)
+			}
+		}
+		return nil
+	}
+	if err := check(config.Settings, "config"); err != nil {
+		return err
+	}
+	for _, profile := range config.ProfileNames() {
+		if err := check(config.Profiles[profile], "profile "+profile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package finder
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"strings"
+	"testing"
+)
+
+// test cases for reading settings and profiles from a config file
+func TestReadConfig(t *testing.T) {
+	config, err := ReadConfig(strings.NewReader(`
+algo = "xxhash"
+parallel = 4
+
+[profiles.photos]
+input-dir = "/data/photos"
+include-ext = ["jpg", "png"]
+images = true
+
+[profiles.builds]
+input-dir = "/src"
+algo = "md5"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Profiles take precedence over the top level settings", func(t *testing.T) {
+		tests := []struct {
+			profile string
+			name    string
+			want    interface{}
+		}{
+			{"", "algo", "xxhash"},
+			{"photos", "algo", "xxhash"},
+			{"builds", "algo", "md5"},
+			{"photos", "parallel", int64(4)},
+			{"photos", "include-ext", []interface{}{"jpg", "png"}},
+			{"photos", "images", true},
+		}
+		for _, test := range tests {
+			got, ok := config.Lookup(test.profile, test.name)
+			if !ok {
+				t.Errorf("%v %v not found", test.profile, test.name)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("%v %v got vs want mismatch (-want +got):\n%s", test.profile, test.name, diff)
+			}
+		}
+		if _, ok := config.Lookup("", "input-dir"); ok {
+			t.Errorf("profile settings should not be used without the profile")
+		}
+		if diff := cmp.Diff([]string{"builds", "photos"}, config.ProfileNames()); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Catch unknown settings", func(t *testing.T) {
+		known := []string{"algo", "parallel", "input-dir", "include-ext", "images"}
+		if err := config.Validate(known); err != nil {
+			t.Errorf("got error %v", err)
+		}
+		err := config.Validate([]string{"algo", "parallel"})
+		if err == nil || !strings.Contains(err.Error(), "profile builds") {
+			t.Errorf("got error %v", err)
+		}
+	})
+
+	t.Run("Profiles must be tables", func(t *testing.T) {
+		if _, err := ReadConfig(strings.NewReader("profiles = \"photos\"\n")); err == nil {
+			t.Errorf("expected an error")
+		}
+		if _, err := ReadConfig(strings.NewReader("[profiles]\nphotos = 1\n")); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
@@ -0,0 +1,243 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// name of the manifest file written inside the destination dir
+const ConsolidateManifestName = "dupefinder-consolidate.tsv"
+
+// what happened to each source file when consolidating
+const (
+	ConsolidateCopied    = "copied"    // file was copied to Stored
+	ConsolidateMoved     = "moved"     // file was moved to Stored
+	ConsolidateExisting  = "existing"  // destination already had a copy of the file at Stored
+	ConsolidateDuplicate = "duplicate" // another source file with the same contents was stored at Stored
+)
+
+type ConsolidateConfig struct {
+	Dest     string   // dir to store one copy of each file in; files keep their path relative to their source dir
+	Move     bool     // move the stored files instead of copying them; should be on the same volume as the sources
+	Manifest string   // path of the manifest to append to; defaults to ConsolidateManifestName inside Dest
+	Journal  *Journal // optional journal to record the moves in so they can be undone
+	Algo     string   // hashing algorithm that was used, recorded in the journal
+	Verbose  bool
+}
+
+// record of where the contents of a single source file are stored
+type ConsolidateRecord struct {
+	Status string
+	Size   int64
+	Source string // path of the file in the source dir
+	Stored string // path of the copy of its contents in the destination dir
+	Time   time.Time
+}
+
+// get a path in the destination that is not taken yet, adding a number to the name if needed
+// e.g. notes.txt -> notes (2).txt
+func freeDestPath(path string) string {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 2; ; i++ {
+		candidate := base + " (" + strconv.Itoa(i) + ")" + ext
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// copy a file to a new path, keeping its permissions and modification time
+// the copy is written to a temp file first so that a partial copy is never left at the path
+func copyFilePreserving(src string, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(dest), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	tempPath, err := copyToTemp(src, filepath.Dir(dest))
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tempPath, info.Mode().Perm())
+	if err == nil {
+		err = os.Chtimes(tempPath, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tempPath, dest)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return err
+}
+
+// merge one or more source dirs into the destination dir, storing a single copy of each file's contents
+// files whose contents are already in the destination, or that were already stored from an earlier file, are
+// not stored again; the sources are searched in order and the files in each source in path order, so the
+// first source wins when the same contents are in several places
+// with Move, only the stored files are moved; the other files are left in the sources
+// files that cannot be stored are skipped with a warning
+// the whole of every file is always hashed, since files that are not stored are only kept in the sources
+func Consolidate(sources []string, findConfig FindConfig, hashConfig HashConfig, config ConsolidateConfig) ([]ConsolidateRecord, error) {
+	records := []ConsolidateRecord{}
+	hashConfig = HashConfig{NumWorkers: hashConfig.NumWorkers, Algo: hashConfig.Algo, Verbose: hashConfig.Verbose}
+	err := os.MkdirAll(config.Dest, os.ModePerm)
+	if err != nil {
+		return records, err
+	}
+
+	// files already in the destination count no matter what the filters are
+	destMap, _ := FindFilesSizes(config.Dest, FindConfig{Verbose: findConfig.Verbose})
+	allMap := map[int64][]FileEntry{}
+	for size, entries := range destMap {
+		allMap[size] = append(allMap[size], entries...)
+	}
+
+	// dont pick up the files that get stored if the destination is inside one of the sources
+	// the dir is compared by its absolute path, since skipping it by name would also skip source dirs with the same name
+	absDest, err := filepath.Abs(config.Dest)
+	if err != nil {
+		return records, err
+	}
+	sourceFiles := make([][]FileEntry, len(sources))
+	for i, source := range sources {
+		sourceMap, _ := FindFilesSizes(source, findConfig)
+		for size, entries := range sourceMap {
+			for _, entry := range entries {
+				if inDir(entry.Path(), absDest) {
+					continue
+				}
+				allMap[size] = append(allMap[size], entry)
+				sourceFiles[i] = append(sourceFiles[i], entry)
+			}
+		}
+		sortFileEntries(sourceFiles[i])
+	}
+
+	// only files with the same size as another file can have the same contents
+	sizeDupes, _ := FindSizeDupes(allMap)
+	hashes := hashesByPath(HashFiles(sizeDupes, hashConfig))
+	contentKey := func(entry FileEntry) string {
+		if hashEntry, ok := hashes[entry.Path()]; ok {
+			return strconv.FormatInt(entry.Size, 10) + ":" + hashEntry.Hash.String()
+		}
+		return "path:" + entry.Path()
+	}
+
+	existing := map[string]string{}
+	for _, entries := range destMap {
+		sortFileEntries(entries)
+		for _, entry := range entries {
+			key := contentKey(entry)
+			if _, ok := existing[key]; !ok {
+				existing[key] = entry.Path()
+			}
+		}
+	}
+
+	stored := map[string]string{}
+	for i, source := range sources {
+		for _, entry := range sourceFiles[i] {
+			key := contentKey(entry)
+			record := ConsolidateRecord{Size: entry.Size, Source: entry.Path(), Time: time.Now()}
+			if path, ok := existing[key]; ok {
+				record.Status = ConsolidateExisting
+				record.Stored = path
+				records = append(records, record)
+				continue
+			}
+			if path, ok := stored[key]; ok {
+				record.Status = ConsolidateDuplicate
+				record.Stored = path
+				records = append(records, record)
+				continue
+			}
+
+			dest, err := relocatePath(entry.Path(), source, config.Dest)
+			if err != nil {
+				logger.Printf("WARNING: Skipping file that could not be stored: %v\n", err)
+				continue
+			}
+			dest = freeDestPath(dest)
+			if config.Move {
+				journalEntry, err := NewJournalEntry(ActionMove, FileHashEntry{File: entry, Hash: hashes[entry.Path()].Hash}, dest, config.Algo)
+				if err == nil {
+					err = os.MkdirAll(filepath.Dir(dest), os.ModePerm)
+				}
+				if err != nil {
+					logger.Printf("WARNING: Skipping file that could not be moved: %v\n", err)
+					continue
+				}
+				if err := config.Journal.record(journalEntry); err != nil {
+					return records, err
+				}
+				if err := os.Rename(entry.Path(), dest); err != nil {
+					logger.Printf("WARNING: Skipping file that could not be moved: %v\n", err)
+					continue
+				}
+				record.Status = ConsolidateMoved
+			} else {
+				if err := copyFilePreserving(entry.Path(), dest); err != nil {
+					logger.Printf("WARNING: Skipping file that could not be copied: %v\n", err)
+					continue
+				}
+				record.Status = ConsolidateCopied
+			}
+			if config.Verbose {
+				logger.Printf("Stored %v at %v\n", entry.Path(), dest)
+			}
+			record.Stored = dest
+			stored[key] = dest
+			records = append(records, record)
+		}
+	}
+
+	manifest := config.Manifest
+	if manifest == "" {
+		manifest = filepath.Join(config.Dest, ConsolidateManifestName)
+	}
+	err = WriteConsolidateManifest(manifest, records)
+	return records, err
+}
+
+// append records to the tab separated consolidate manifest, writing the header if the file is new
+func WriteConsolidateManifest(path string, records []ConsolidateRecord) error {
+	_, statErr := os.Stat(path)
+	newFile := os.IsNotExist(statErr)
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if newFile {
+		_, err = file.WriteString("time\tstatus\tsize\tsource\tstored\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, record := range records {
+		line := record.Time.Format(time.RFC3339) + "\t" +
+			record.Status + "\t" +
+			strconv.FormatInt(record.Size, 10) + "\t" +
+			record.Source + "\t" +
+			record.Stored + "\n"
+		_, err = file.WriteString(line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package finder
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formats accepted for absolute dates in ParseTimeBound
+var timeBoundLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// matches ages like 30d, 2w, 12h, 1.5d
+var agePattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)([smhdwy])$`)
+
+// units for ages, in addition to the ones understood by time.ParseDuration
+var ageUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+	"y": 365 * 24 * time.Hour,
+}
+
+// parse a point in time for use with FindConfig.NewerThan and FindConfig.OlderThan
+// accepts an absolute date (2006-01-02, 2006-01-02T15:04:05, or RFC3339) in local time,
+// or an age relative to now such as 30d, 2w, 12h, or a Go duration like 1h30m
+func ParseTimeBound(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range timeBoundLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	if match := agePattern.FindStringSubmatch(value); match != nil {
+		num, err := strconv.ParseFloat(match[1], 64)
+		if err == nil {
+			age := time.Duration(num * float64(ageUnits[match[2]]))
+			return now.Add(-age), nil
+		}
+	}
+	if age, err := time.ParseDuration(value); err == nil {
+		return now.Add(-age), nil
+	}
+	return time.Time{}, fmt.Errorf("could not parse %q as a date (e.g. 2006-01-02) or an age (e.g. 30d, 12h)", value)
+}
+
+// check if a modification time falls inside the window set in the config
+func (config FindConfig) passModTime(modTime time.Time) bool {
+	if config.NewerThan != nil && !modTime.After(*config.NewerThan) {
+		return false
+	}
+	if config.OlderThan != nil && !modTime.Before(*config.OlderThan) {
+		return false
+	}
+	return true
+}
+
+// set of patterns to match files against by name or by their path relative to the dir being searched
+type PathFilter struct {
+	Extensions []string         // file extensions, with or without the leading dot; case insensitive
+	Globs      []string         // shell globs; matched against the relative path if they contain a '/', otherwise against the file name
+	Regexps    []*regexp.Regexp // matched anywhere in the relative path, using '/' as the separator
+}
+
+// make a PathFilter, checking that all the globs and regular expressions are valid
+func NewPathFilter(extensions []string, globs []string, regexps []string) (PathFilter, error) {
+	filter := PathFilter{Globs: globs}
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext != "" {
+			filter.Extensions = append(filter.Extensions, ext)
+		}
+	}
+	for _, glob := range globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return filter, fmt.Errorf("invalid glob %q: %v", glob, err)
+		}
+	}
+	for _, pattern := range regexps {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return filter, fmt.Errorf("invalid regular expression %q: %v", pattern, err)
+		}
+		filter.Regexps = append(filter.Regexps, re)
+	}
+	return filter, nil
+}
+
+// check if the filter has any patterns at all
+func (filter PathFilter) IsEmpty() bool {
+	return len(filter.Extensions) == 0 && len(filter.Globs) == 0 && len(filter.Regexps) == 0
+}
+
+// check if a file matches any of the patterns in the filter
+// relPath is the path of the file relative to the dir being searched
+func (filter PathFilter) Match(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	name := path.Base(relPath)
+
+	if len(filter.Extensions) > 0 {
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+		for _, want := range filter.Extensions {
+			if ext == want {
+				return true
+			}
+		}
+	}
+	for _, glob := range filter.Globs {
+		target := name
+		if strings.Contains(glob, "/") {
+			target = relPath
+		}
+		if matched, _ := path.Match(glob, target); matched {
+			return true
+		}
+	}
+	for _, re := range filter.Regexps {
+		if re.MatchString(relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// check if a file passes the include and exclude filters set in the config
+func (config FindConfig) passPathFilters(relPath string) bool {
+	if !config.Include.IsEmpty() && !config.Include.Match(relPath) {
+		return false
+	}
+	if !config.Exclude.IsEmpty() && config.Exclude.Match(relPath) {
+		return false
+	}
+	return true
+}
+
+// get how deep a path is below the search dir; files directly in the search dir have depth 1
+func pathDepth(relPath string) int {
+	if relPath == "." || relPath == "" {
+		return 0
+	}
+	return strings.Count(filepath.ToSlash(relPath), "/") + 1
+}
+
+// dotfiles and dotdirs are hidden
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".") && name != "." && name != ".."
+}
+
+// check if a dir at the given depth should be skipped entirely; the search dir itself is never skipped
+func (config FindConfig) skipDir(depth int, name string) bool {
+	if depth == 0 {
+		return false
+	}
+	if config.SkipHidden && isHidden(name) {
+		return true
+	}
+	// files in this dir would be deeper than the limit
+	return config.MaxDepth > 0 && depth >= config.MaxDepth
+}
+
+// check if a file at the given depth passes the depth and hidden file filters
+func (config FindConfig) passDepth(depth int, name string) bool {
+	if config.SkipHidden && isHidden(name) {
+		return false
+	}
+	if depth < config.MinDepth {
+		return false
+	}
+	return config.MaxDepth <= 0 || depth <= config.MaxDepth
+}
@@ -1,17 +1,31 @@
 package finder
 
 import (
+	"bufio"
+	"io"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 type FindConfig struct {
-	MinSize  int64
-	MaxSize  *int64 // zero value nil allows to check if value was set
-	SkipDirs []string
-	Verbose  bool // false by default
+	MinSize    int64
+	MaxSize    *int64     // zero value nil allows to check if value was set
+	NewerThan  *time.Time // only include files modified after this time; nil = no limit
+	OlderThan  *time.Time // only include files modified before this time; nil = no limit
+	Include    PathFilter // if set, only include files that match
+	Exclude    PathFilter // skip files that match
+	MinDepth   int        // only include files at least this many dirs deep; files directly in the search dir have depth 1
+	MaxDepth   int        // only include files at most this many dirs deep; 0 = no limit
+	SkipHidden bool       // skip files and dirs whose names start with a '.'
+	SkipEmpty  bool       // skip zero byte files, which would otherwise all be duplicates of each other
+	SkipDirs   []string
+	SkipPaths  []string // absolute paths of dirs to skip with everything inside them; unlike SkipDirs these never match by name
+	LowMemory  bool     // FindDupes only keeps the files whose size is shared, see FindSizeDupeFiles; otherwise every file is kept until the end
+	Verbose    bool     // false by default
 }
 
 // check if a slice contains a specific string
@@ -34,16 +48,63 @@ func containsFileHashEntry(l []FileHashEntry, e FileHashEntry) bool {
 	return false
 }
 
-// find all files in the directory tree and group them by file size
-func FindFilesSizes(dirPath string, config FindConfig) (map[int64][]FileEntry, uint64) {
-	fileMap := map[int64][]FileEntry{}
-	var numFiles uint64
+// check if a file size is within the limits set in the config
+func (config FindConfig) passSize(size int64) bool {
+	if config.SkipEmpty && size == 0 {
+		return false
+	}
 
-	if config.Verbose {
-		logger.Printf("Searching for files in path %v\n", dirPath)
+	// default to false
+	var passMinSize bool
+	var passMaxSize bool
+
+	if size >= config.MinSize {
+		passMinSize = true
 	}
 
-	err := filepath.Walk(dirPath, func(path string, info fs.FileInfo, err error) error {
+	// MaxSize automatically passes if no value was given
+	if config.MaxSize == nil {
+		passMaxSize = true
+	} else {
+		if size <= *config.MaxSize {
+			passMaxSize = true
+		}
+	}
+
+	return passMinSize && passMaxSize
+}
+
+// check if a path is inside the dir, which must be an absolute path
+func inDir(path string, dir string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// check if a path is inside one of the SkipPaths
+func (config FindConfig) inSkipPath(path string) bool {
+	for _, dir := range config.SkipPaths {
+		if inDir(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// check if a regular file passes all of the filters in the config
+func (config FindConfig) includeFile(relPath string, depth int, info fs.FileInfo) bool {
+	return config.passSize(info.Size()) &&
+		config.passDepth(depth, info.Name()) &&
+		config.passModTime(info.ModTime()) &&
+		config.passPathFilters(relPath)
+}
+
+// walk the directory tree and call found for every regular file that passes the filters in the config
+func walkFiles(dirPath string, config FindConfig, found func(path string, info fs.FileInfo)) error {
+	return filepath.Walk(dirPath, func(path string, info fs.FileInfo, err error) error {
 		// skip item that cannot be read
 		if os.IsPermission(err) {
 			logger.Printf("Skipping path that could not be read %q: %v\n", path, err)
@@ -61,50 +122,205 @@ func FindFilesSizes(dirPath string, config FindConfig) (map[int64][]FileEntry, u
 			logger.Printf("skipping a dir: %+v %v \n", info.Name(), path)
 			return filepath.SkipDir
 		}
-
-		// if its a file then add it to the list
-		if info.Mode().IsRegular() {
-			// test for file size filters
-			size := info.Size()
-			// default to false
-			var passMinSize bool
-			var passMaxSize bool
-			var passSize bool
-
-			if size >= config.MinSize {
-				passMinSize = true
+		if info.IsDir() && config.inSkipPath(path) {
+			if config.Verbose {
+				logger.Printf("Skipping dir %v\n", path)
 			}
+			return filepath.SkipDir
+		}
 
-			// MaxSize automatically passes if no value was given
-			if config.MaxSize == nil {
-				passMaxSize = true
-			} else {
-				if size <= *config.MaxSize {
-					passMaxSize = true
-				}
-			}
+		// filter on the path relative to the search dir
+		relPath, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			relPath = path
+		}
+		depth := pathDepth(relPath)
 
-			if passMinSize && passMaxSize {
-				passSize = true
-			}
+		// dont walk into dirs that can only hold files that would get filtered out anyway
+		if info.IsDir() && config.skipDir(depth, info.Name()) {
+			return filepath.SkipDir
+		}
 
-			if passSize {
-				fileEntry := NewFileEntryFromPathInfo(path, info)
-				fileMap[size] = append(fileMap[size], fileEntry)
-				numFiles += 1
-			}
+		// if its a file then add it to the list
+		if info.Mode().IsRegular() && config.includeFile(relPath, depth, info) {
+			found(path, info)
 		}
 		return nil
 	})
+}
 
+// find all files in the directory tree and group them by file size
+// exits if the tree cannot be walked; use findFilesSizes to get the error instead
+func FindFilesSizes(dirPath string, config FindConfig) (map[int64][]FileEntry, uint64) {
+	fileMap, numFiles, err := findFilesSizes(dirPath, config)
 	if err != nil {
 		log.Fatalf("error walking the path %q: %v\n", dirPath, err)
 	}
+	return fileMap, numFiles
+}
+
+func findFilesSizes(dirPath string, config FindConfig) (map[int64][]FileEntry, uint64, error) {
+	fileMap := map[int64][]FileEntry{}
+	var numFiles uint64
+	dirs := dirInterner{}
+
+	if config.Verbose {
+		logger.Printf("Searching for files in path %v\n", dirPath)
+	}
+
+	err := walkFiles(dirPath, config, func(path string, info fs.FileInfo) {
+		size := info.Size()
+		fileEntry := dirs.newFileEntry(path, info)
+		fileMap[size] = append(fileMap[size], fileEntry)
+		numFiles += 1
+	})
+	if err != nil {
+		return fileMap, numFiles, err
+	}
 
 	if config.Verbose {
 		logger.Printf("Found %v files\n", numFiles)
 	}
 
+	return fileMap, numFiles, nil
+}
+
+// find the files in the directory tree that have the same size as another file, like FindSizeDupes(FindFilesSizes(...))
+// but without holding on to the files with a unique size; the tree is searched twice, first to count the files of each
+// size and then to keep the files whose size is shared, which takes a lot less memory on large trees
+// the number of files is from the first search
+// exits if the tree cannot be walked; use findSizeDupeFiles to get the error instead
+func FindSizeDupeFiles(dirPath string, config FindConfig) (map[int64][]FileEntry, uint64) {
+	sizeDupes, numFiles, err := findSizeDupeFiles(dirPath, config)
+	if err != nil {
+		log.Fatalf("error walking the path %q: %v\n", dirPath, err)
+	}
+	return sizeDupes, numFiles
+}
+
+func findSizeDupeFiles(dirPath string, config FindConfig) (map[int64][]FileEntry, uint64, error) {
+	var numFiles uint64
+
+	if config.Verbose {
+		logger.Printf("Counting file sizes in path %v\n", dirPath)
+	}
+
+	sizeCounts := map[int64]uint32{}
+	err := walkFiles(dirPath, config, func(path string, info fs.FileInfo) {
+		if sizeCounts[info.Size()] < 2 {
+			sizeCounts[info.Size()] += 1
+		}
+		numFiles += 1
+	})
+	if err != nil {
+		return nil, numFiles, err
+	}
+	sharedSizes := map[int64]bool{}
+	for size, count := range sizeCounts {
+		if count > 1 {
+			sharedSizes[size] = true
+		}
+	}
+	sizeCounts = nil
+
+	if config.Verbose {
+		logger.Printf("Found %v files, %v sizes are shared by more than one file\n", numFiles, len(sharedSizes))
+	}
+
+	fileMap := map[int64][]FileEntry{}
+	dirs := dirInterner{}
+	err = walkFiles(dirPath, config, func(path string, info fs.FileInfo) {
+		size := info.Size()
+		if sharedSizes[size] {
+			fileMap[size] = append(fileMap[size], dirs.newFileEntry(path, info))
+		}
+	})
+	if err != nil {
+		return nil, numFiles, err
+	}
+
+	// files that changed between the two searches can leave a size with a single file
+	sizeDupes, _ := FindSizeDupes(fileMap)
+	return sizeDupes, numFiles, nil
+}
+
+// read a list of file paths, one per line, or separated by NUL characters as written by 'find -print0'
+// empty entries are skipped
+func ReadFileList(reader io.Reader, nulSeparated bool) ([]string, error) {
+	var sep byte = '\n'
+	if nulSeparated {
+		sep = 0
+	}
+	paths := []string{}
+	bufReader := bufio.NewReader(reader)
+	for {
+		item, err := bufReader.ReadString(sep)
+		item = strings.TrimSuffix(item, string(sep))
+		if item != "" {
+			paths = append(paths, item)
+		}
+		if err == io.EOF {
+			return paths, nil
+		}
+		if err != nil {
+			return paths, err
+		}
+	}
+}
+
+// group an explicit list of files by file size instead of searching a dir for them
+// the filters are the same as FindFilesSizes except for the depth limits, which need a search dir
+// the path filters match the path relative to root, or the path as listed if it is outside of root
+// paths that are missing, not regular files, or listed more than once are skipped
+func FindListFilesSizes(paths []string, root string, config FindConfig) (map[int64][]FileEntry, uint64) {
+	fileMap := map[int64][]FileEntry{}
+	var numFiles uint64
+	seen := map[string]bool{}
+	dirs := dirInterner{}
+
+	config.MinDepth = 0
+	config.MaxDepth = 0
+	absRoot, rootErr := filepath.Abs(root)
+
+	for _, path := range paths {
+		// a file listed twice would look like a duplicate of itself, even when it is listed by a different path
+		path = filepath.Clean(path)
+		info, err := os.Lstat(path)
+		if err != nil {
+			logger.Printf("Skipping path that could not be read %q: %v\n", path, err)
+			continue
+		}
+		key, err := filepath.Abs(path)
+		if err == nil {
+			key, err = filepath.EvalSymlinks(key)
+		}
+		if err != nil {
+			logger.Printf("Skipping path that could not be resolved %q: %v\n", path, err)
+			continue
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if config.inSkipPath(key) {
+			continue
+		}
+		relPath := path
+		if absPath, err := filepath.Abs(path); err == nil && rootErr == nil && inDir(absPath, absRoot) {
+			relPath, _ = filepath.Rel(absRoot, absPath)
+		}
+		if info.Mode().IsRegular() && config.includeFile(relPath, 1, info) {
+			size := info.Size()
+			fileEntry := dirs.newFileEntry(path, info)
+			fileMap[size] = append(fileMap[size], fileEntry)
+			numFiles += 1
+		}
+	}
+
+	if config.Verbose {
+		logger.Printf("Found %v files in a list of %v paths\n", numFiles, len(paths))
+	}
+
 	return fileMap, numFiles
 }
 
@@ -122,10 +338,55 @@ func FindSizeDupes(fileSizeMap map[int64][]FileEntry) (map[int64][]FileEntry, in
 
 // find all the duplicate files in the dir
 // Duplicates = same file size, same hash value
+// exits if the tree cannot be walked; use findDupes to get the error instead
 // TODO: this might need to be broken up to aid garbage collection ??
-func FindDupes(dirPath string, findConfig FindConfig, hashConfig HashConfig) (map[string][]FileHashEntry, uint64) {
-	fileSizeMap, numAllFiles := FindFilesSizes(dirPath, findConfig)
-	sizeDupes, numSizeDupes := FindSizeDupes(fileSizeMap)
+func FindDupes(dirPath string, findConfig FindConfig, hashConfig HashConfig) (map[HashSum][]FileHashEntry, uint64) {
+	dupes, numAllFiles, err := findDupes(dirPath, findConfig, hashConfig)
+	if err != nil {
+		log.Fatalf("error walking the path %q: %v\n", dirPath, err)
+	}
+	return dupes, numAllFiles
+}
+
+func findDupes(dirPath string, findConfig FindConfig, hashConfig HashConfig) (map[HashSum][]FileHashEntry, uint64, error) {
+	// files of different sizes can still match once decompressed or normalized, so they are all needed for those
+	if findConfig.LowMemory && !hashConfig.Decompress && !hashConfig.NormalizeText {
+		sizeDupes, numAllFiles, err := findSizeDupeFiles(dirPath, findConfig)
+		if err != nil {
+			return nil, numAllFiles, err
+		}
+		dupes, numAllFiles := findDupesFromSizes(sizeDupes, numAllFiles, findConfig, hashConfig)
+		return dupes, numAllFiles, nil
+	}
+	fileSizeMap, numAllFiles, err := findFilesSizes(dirPath, findConfig)
+	if err != nil {
+		return nil, numAllFiles, err
+	}
+	dupes, numAllFiles := findDupesFromSizes(fileSizeMap, numAllFiles, findConfig, hashConfig)
+	return dupes, numAllFiles, nil
+}
+
+// find all the duplicates in an explicit list of files, the same way as FindDupes
+// the path filters match the paths relative to root, as they would when searching root
+func FindListDupes(paths []string, root string, findConfig FindConfig, hashConfig HashConfig) (map[HashSum][]FileHashEntry, uint64) {
+	fileSizeMap, numAllFiles := FindListFilesSizes(paths, root, findConfig)
+	return findDupesFromSizes(fileSizeMap, numAllFiles, findConfig, hashConfig)
+}
+
+// find the duplicates among files that have already been grouped by size
+func findDupesFromSizes(fileSizeMap map[int64][]FileEntry, numAllFiles uint64, findConfig FindConfig, hashConfig HashConfig) (map[HashSum][]FileHashEntry, uint64) {
+	// compressed files need to line up with their uncompressed copies before looking for matching sizes
+	if hashConfig.Decompress {
+		fileSizeMap = GroupByDecompressedSize(fileSizeMap)
+	}
+
+	var sizeDupes map[int64][]FileEntry
+	var numSizeDupes int
+	if hashConfig.NormalizeText {
+		sizeDupes, numSizeDupes = FindTextSizeDupes(fileSizeMap)
+	} else {
+		sizeDupes, numSizeDupes = FindSizeDupes(fileSizeMap)
+	}
 
 	if findConfig.Verbose {
 		logger.Printf("Found %v size duplicates\n", numSizeDupes)
@@ -5,8 +5,11 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 // test cases for finding files
@@ -17,6 +20,14 @@ func TestFindFiles(t *testing.T) {
 
 	var maxsize int64 = 5
 
+	// make one of the files old
+	oldTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := os.Chtimes(tempFiles[0].Name(), oldTime, oldTime)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cutoff := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+
 	tests := map[string]struct {
 		config       FindConfig
 		wantFiles    map[int64][]FileEntry
@@ -72,6 +83,50 @@ func TestFindFiles(t *testing.T) {
 			},
 			wantNumFiles: uint64(4),
 		},
+		"max_depth": {
+			config: FindConfig{MaxDepth: 1},
+			wantFiles: map[int64][]FileEntry{
+				0: []FileEntry{
+					NewFileEntryFromPath(tempFiles[2].Name()),
+				},
+			},
+			wantNumFiles: uint64(1),
+		},
+		"min_depth": {
+			config: FindConfig{MinDepth: 2},
+			wantFiles: map[int64][]FileEntry{
+				0: []FileEntry{
+					NewFileEntryFromPath(tempFiles[1].Name()),
+					NewFileEntryFromPath(tempFiles[3].Name()),
+					NewFileEntryFromPath(tempFiles[4].Name()),
+				},
+				7: []FileEntry{
+					NewFileEntryFromPath(tempFiles[0].Name()),
+				},
+			},
+			wantNumFiles: uint64(4),
+		},
+		"skip_old_files": {
+			config: FindConfig{NewerThan: &cutoff},
+			wantFiles: map[int64][]FileEntry{
+				0: []FileEntry{
+					NewFileEntryFromPath(tempFiles[2].Name()),
+					NewFileEntryFromPath(tempFiles[1].Name()),
+					NewFileEntryFromPath(tempFiles[3].Name()),
+					NewFileEntryFromPath(tempFiles[4].Name()),
+				},
+			},
+			wantNumFiles: uint64(4),
+		},
+		"skip_new_files": {
+			config: FindConfig{OlderThan: &cutoff},
+			wantFiles: map[int64][]FileEntry{
+				7: []FileEntry{
+					NewFileEntryFromPath(tempFiles[0].Name()),
+				},
+			},
+			wantNumFiles: uint64(1),
+		},
 	}
 
 	for name, tc := range tests {
@@ -91,6 +146,114 @@ func TestFindFiles(t *testing.T) {
 	}
 }
 
+// test cases for including and excluding files by extension, glob, and regex
+func TestFindFilesFilters(t *testing.T) {
+	tempdir := t.TempDir()
+	photos := createSubDir(tempdir, "photos")
+	logs := createSubDir(tempdir, "logs")
+	paths := []string{
+		filepath.Join(photos, "a.JPG"),
+		filepath.Join(photos, "b.png"),
+		filepath.Join(photos, "b.tmp"),
+		filepath.Join(logs, "c.log"),
+		filepath.Join(logs, "c.txt"),
+	}
+	for _, path := range paths {
+		if err := os.WriteFile(path, []byte("foo\n"), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	tests := map[string]struct {
+		exts         []string
+		globs        []string
+		regexps      []string
+		exclude      bool
+		wantNumFiles uint64
+	}{
+		"include_ext":      {exts: []string{"jpg", ".png"}, wantNumFiles: 2},
+		"exclude_ext":      {exts: []string{"log", "tmp"}, exclude: true, wantNumFiles: 3},
+		"include_glob":     {globs: []string{"c.*"}, wantNumFiles: 2},
+		"include_path":     {globs: []string{"photos/*"}, wantNumFiles: 3},
+		"exclude_regex":    {regexps: []string{`^logs/`}, exclude: true, wantNumFiles: 3},
+		"include_combined": {exts: []string{"log"}, regexps: []string{`\.png$`}, wantNumFiles: 2},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			filter, err := NewPathFilter(tc.exts, tc.globs, tc.regexps)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			config := FindConfig{Include: filter}
+			if tc.exclude {
+				config = FindConfig{Exclude: filter}
+			}
+			_, gotNumFiles := FindFilesSizes(tempdir, config)
+			if diff := cmp.Diff(tc.wantNumFiles, gotNumFiles); diff != "" {
+				t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("Skip hidden files and dirs", func(t *testing.T) {
+		hiddenDir := createSubDir(tempdir, ".cache")
+		createTempFile(hiddenDir, "d.", "foo\n")
+		createTempFile(photos, ".e.", "foo\n")
+		_, gotNumFiles := FindFilesSizes(tempdir, FindConfig{SkipHidden: true})
+		if diff := cmp.Diff(uint64(len(paths)), gotNumFiles); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+		_, gotNumFiles = FindFilesSizes(tempdir, FindConfig{})
+		if diff := cmp.Diff(uint64(len(paths)+2), gotNumFiles); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Reject invalid patterns", func(t *testing.T) {
+		if _, err := NewPathFilter(nil, []string{"[a-"}, nil); err == nil {
+			t.Errorf("expected an error for invalid glob")
+		}
+		if _, err := NewPathFilter(nil, nil, []string{"(a"}); err == nil {
+			t.Errorf("expected an error for invalid regex")
+		}
+	})
+}
+
+// test cases for parsing dates and ages for the modification time filters
+func TestParseTimeBound(t *testing.T) {
+	now := time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		value string
+		want  time.Time
+	}{
+		"days":     {value: "30d", want: now.Add(-30 * 24 * time.Hour)},
+		"weeks":    {value: "2w", want: now.Add(-14 * 24 * time.Hour)},
+		"duration": {value: "1h30m", want: now.Add(-90 * time.Minute)},
+		"date":     {value: "2022-01-02", want: time.Date(2022, 1, 2, 0, 0, 0, 0, time.Local)},
+		"rfc3339":  {value: "2022-01-02T03:04:05Z", want: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseTimeBound(tc.value, now)
+			if err != nil {
+				t.Errorf("got error %v", err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("got %v is not the same as %v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Reject invalid values", func(t *testing.T) {
+		_, err := ParseTimeBound("last tuesday", now)
+		if err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
+
 // test for finding duplicate files
 func TestFindDupes(t *testing.T) {
 	// set up temp dirs for tests
@@ -101,8 +264,8 @@ func TestFindDupes(t *testing.T) {
 		hashConfig := HashConfig{NumWorkers: 2}
 		findConfig := FindConfig{SkipDirs: []string{tempDirs[2]}}
 		gotDupes, gotNumFiles := FindDupes(tempdir, findConfig, hashConfig)
-		wantHash := "d41d8cd98f00b204e9800998ecf8427e"
-		wantDupes := map[string][]FileHashEntry{
+		wantHash := hashSum("d41d8cd98f00b204e9800998ecf8427e")
+		wantDupes := map[HashSum][]FileHashEntry{
 			wantHash: []FileHashEntry{
 				NewFileHashEntry(NewFileEntryFromPath(tempFiles[2].Name()), hashConfig),
 				NewFileHashEntry(NewFileEntryFromPath(tempFiles[1].Name()), hashConfig),
@@ -133,7 +296,7 @@ func TestFindDupes(t *testing.T) {
 		gotFormat := DupesFormatter(gotDupes[wantHash], config)
 		var wantFormat string
 		for _, entry := range wantDupes[wantHash] {
-			wantFormat += wantHash + "\t" + entry.File.Path + "\n"
+			wantFormat += wantHash.String() + "\t" + entry.File.Path() + "\n"
 		}
 
 		if diff := cmp.Diff(wantFormat, gotFormat); diff != "" {
@@ -141,6 +304,120 @@ func TestFindDupes(t *testing.T) {
 		}
 
 	})
+
+	t.Run("Test find dupes without keeping files with a unique size", func(t *testing.T) {
+		findConfig := FindConfig{}
+		hashConfig := HashConfig{NumWorkers: 2}
+		fileSizeMap, _ := FindFilesSizes(tempdir, findConfig)
+		wantSizeDupes, _ := FindSizeDupes(fileSizeMap)
+		gotSizeDupes, gotNumFiles := FindSizeDupeFiles(tempdir, findConfig)
+		if diff := cmp.Diff(wantSizeDupes, gotSizeDupes); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+		// every file is counted, not just the ones that were kept
+		if gotNumFiles != 5 {
+			t.Errorf("got %v files", gotNumFiles)
+		}
+
+		// the files in each group are hashed in no particular order
+		sortDupes := func(dupes map[HashSum][]FileHashEntry) map[HashSum][]FileHashEntry {
+			for _, entries := range dupes {
+				sort.Slice(entries, func(i, j int) bool { return entries[i].File.Path() < entries[j].File.Path() })
+			}
+			return dupes
+		}
+		wantDupes, _ := FindDupes(tempdir, findConfig, hashConfig)
+		findConfig.LowMemory = true
+		gotDupes, _ := FindDupes(tempdir, findConfig, hashConfig)
+		if diff := cmp.Diff(sortDupes(wantDupes), sortDupes(gotDupes)); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+// test for finding duplicates from an explicit list of files
+func TestFindListDupes(t *testing.T) {
+	tempdir := t.TempDir()
+	dir := createSubDir(tempdir, "dir")
+	paths := []string{
+		filepath.Join(tempdir, "a.txt"),
+		filepath.Join(dir, "b.txt"),
+		filepath.Join(dir, "new\nline.txt"),
+		filepath.Join(dir, "c.log"),
+	}
+	for _, path := range paths {
+		if err := os.WriteFile(path, []byte("foo\n"), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	t.Run("Read file lists", func(t *testing.T) {
+		got, err := ReadFileList(strings.NewReader("a\n\nb c\nd"), false)
+		if err != nil {
+			t.Errorf("got error %v", err)
+		}
+		if diff := cmp.Diff([]string{"a", "b c", "d"}, got); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+		got, err = ReadFileList(strings.NewReader("a\nb\x00c\x00"), true)
+		if err != nil {
+			t.Errorf("got error %v", err)
+		}
+		if diff := cmp.Diff([]string{"a\nb", "c"}, got); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Find dupes in the list only", func(t *testing.T) {
+		// list a file twice, a missing file, and a dir, and leave c.log out
+		list := []string{paths[0], paths[1], paths[2], paths[0] + "/", filepath.Join(dir, "missing"), dir}
+		exclude, _ := NewPathFilter([]string{"log"}, nil, nil)
+		gotDupes, gotNumFiles := FindListDupes(list, tempdir, FindConfig{Exclude: exclude}, HashConfig{NumWorkers: 1})
+		if diff := cmp.Diff(uint64(3), gotNumFiles); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+		gotPaths := []string{}
+		for _, entry := range gotDupes[hashSum("d3b07384d113edec49eaa6238ad5ff00")] {
+			gotPaths = append(gotPaths, entry.File.Path())
+		}
+		sort.Strings(gotPaths)
+		if diff := cmp.Diff(paths[:3], gotPaths); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Match the path filters relative to the root", func(t *testing.T) {
+		// the temp dir's own path must not count, so only the files below dir match
+		include, _ := NewPathFilter(nil, nil, []string{"^dir/"})
+		_, gotNumFiles := FindListDupes(paths, tempdir, FindConfig{Include: include}, HashConfig{NumWorkers: 1})
+		if diff := cmp.Diff(uint64(3), gotNumFiles); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Skip files listed by both relative and absolute paths", func(t *testing.T) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chdir(tempdir); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chdir(cwd)
+		if err := os.Symlink(dir, filepath.Join(tempdir, "link")); err != nil {
+			t.Fatal(err)
+		}
+
+		// the same file through a relative path, an absolute path, and a symlinked dir
+		list := []string{filepath.Join("dir", "b.txt"), paths[1], filepath.Join(tempdir, "link", "b.txt"), "./dir/../dir/b.txt"}
+		gotDupes, gotNumFiles := FindListDupes(list, tempdir, FindConfig{}, HashConfig{NumWorkers: 1})
+		if diff := cmp.Diff(uint64(1), gotNumFiles); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+		if len(gotDupes) != 0 {
+			t.Errorf("got dupes %v, a file is not a duplicate of itself", gotDupes)
+		}
+	})
 }
 
 func TestTooManyFiles(t *testing.T) {
@@ -173,8 +450,8 @@ func TestTooManyFiles(t *testing.T) {
 		hashConfig := HashConfig{NumWorkers: 2}
 		findConfig := FindConfig{SkipDirs: []string{}}
 		gotHashDupes, gotNumFiles := FindDupes(tempdir, findConfig, hashConfig)
-		wantHashDupes := map[string][]FileHashEntry{
-			"acbd18db4cc2f85cedef654fccc4a4d8": []FileHashEntry{
+		wantHashDupes := map[HashSum][]FileHashEntry{
+			hashSum("acbd18db4cc2f85cedef654fccc4a4d8"): []FileHashEntry{
 				NewFileHashEntry(NewFileEntryFromPath(tempfile1.Name()), hashConfig),
 				NewFileHashEntry(NewFileEntryFromPath(tempfile2.Name()), hashConfig),
 			},
@@ -209,7 +486,7 @@ func TestPermissionsError(t *testing.T) {
 		findConfig := FindConfig{SkipDirs: []string{}}
 		hashConfig := HashConfig{NumWorkers: 2}
 		got, _ := FindDupes(tempdir, findConfig, hashConfig)
-		want := map[string][]FileHashEntry{}
+		want := map[HashSum][]FileHashEntry{}
 		if diff := cmp.Diff(want, got); diff != "" {
 			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
 		}
@@ -247,8 +524,8 @@ func TestPermissionsError(t *testing.T) {
 		hashConfig := HashConfig{NumWorkers: 2}
 		findConfig := FindConfig{SkipDirs: []string{}}
 		got, _ := FindDupes(subdir1, findConfig, hashConfig)
-		want := map[string][]FileHashEntry{
-			"d3b07384d113edec49eaa6238ad5ff00": []FileHashEntry{
+		want := map[HashSum][]FileHashEntry{
+			hashSum("d3b07384d113edec49eaa6238ad5ff00"): []FileHashEntry{
 				NewFileHashEntry(NewFileEntryFromPath(tempfile3.Name()), hashConfig),
 				NewFileHashEntry(NewFileEntryFromPath(tempfile4.Name()), hashConfig),
 			},
@@ -17,9 +17,9 @@ func DupesFormatter(dupes []FileHashEntry, config FormatConfig) string {
 	for _, entry := range dupes {
 		var s string
 		if config.Size {
-			s = entry.Hash + "\t" + strconv.FormatInt(entry.File.Size, 10) + "\t" + entry.File.Path + "\n"
+			s = entry.Hash.String() + "\t" + strconv.FormatInt(entry.File.Size, 10) + "\t" + entry.File.Path() + hashEntryNotes(entry) + "\n"
 		} else {
-			s = entry.Hash + "\t" + entry.File.Path + "\n"
+			s = entry.Hash.String() + "\t" + entry.File.Path() + hashEntryNotes(entry) + "\n"
 		}
 		lines = append(lines, s)
 	}
@@ -30,12 +30,27 @@ func DupesFormatter(dupes []FileHashEntry, config FormatConfig) string {
 	return outputStr
 }
 
+// extra column describing how the hash was made, if it was not a plain hash of the whole file
+func hashEntryNotes(entry FileHashEntry) string {
+	var notes string
+	if entry.Sample != "" {
+		notes += "\t" + entry.Sample
+	}
+	if entry.Normalized {
+		notes += "\tnormalized-text"
+	}
+	if entry.Decompressed != "" {
+		notes += "\tdecompressed:" + entry.Decompressed
+	}
+	return notes
+}
+
 func FileEntryFormatter(dupes []FileEntry) string {
 	var outputStr string
 	lines := []string{}
 	for _, entry := range dupes {
 		var s string
-		s = strconv.FormatInt(entry.Size, 10) + "\t" + entry.Path + "\n"
+		s = strconv.FormatInt(entry.Size, 10) + "\t" + entry.Path() + "\n"
 		lines = append(lines, s)
 	}
 	sort.Strings(lines)
@@ -44,3 +59,135 @@ func FileEntryFormatter(dupes []FileEntry) string {
 	}
 	return outputStr
 }
+
+// convert a group of similar images to lines to be printed to console
+// every line starts with the hash of the first image so that the groups stay together when sorted
+func ImageDupesFormatter(group []ImageHashEntry, config FormatConfig) string {
+	var outputStr string
+	if len(group) == 0 {
+		return outputStr
+	}
+	groupHash := group[0].HashString()
+	for _, entry := range group {
+		outputStr += groupHash + "\t"
+		if config.Size {
+			outputStr += strconv.FormatInt(entry.File.Size, 10) + "\t"
+		}
+		outputStr += entry.File.Path() + "\t" +
+			strconv.Itoa(entry.Width) + "x" + strconv.Itoa(entry.Height) + "\t" +
+			"distance:" + strconv.Itoa(entry.Distance) + "\n"
+	}
+	return outputStr
+}
+
+// convert a pair of similar files to a line to be printed to console
+func SimilarPairFormatter(pair SimilarPair) string {
+	return strconv.FormatFloat(pair.Percent, 'f', 1, 64) + "%\t" +
+		strconv.FormatInt(pair.SharedBytes, 10) + "\t" +
+		pair.A.Path() + "\t" +
+		pair.B.Path() + "\n"
+}
+
+// convert a group of files with the same name to lines to be printed to console
+// each line has a version number so that the copies with the same contents can be told apart from the others
+func NameGroupFormatter(group NameGroup, config FormatConfig) string {
+	var outputStr string
+	for i, entry := range group.Entries {
+		outputStr += group.Name + "\tv" + strconv.Itoa(group.Versions[i]) + "\t"
+		if config.Size {
+			outputStr += strconv.FormatInt(entry.File.Size, 10) + "\t"
+		}
+		outputStr += entry.File.Path() + "\n"
+	}
+	return outputStr
+}
+
+// convert a junk report to lines to be printed to console, with the type of junk at the start of each line
+func JunkFormatter(report JunkReport) string {
+	var outputStr string
+	for _, entry := range report.EmptyFiles {
+		outputStr += "empty-file\t" + entry.Path() + "\n"
+	}
+	for _, dir := range report.EmptyDirs {
+		outputStr += "empty-dir\t" + dir + "\n"
+	}
+	for _, link := range report.BrokenSymlinks {
+		outputStr += "broken-symlink\t" + link.Path + "\t" + link.Target + "\n"
+	}
+	return outputStr
+}
+
+// convert a space report to a table of dirs and a table of dir pairs to be printed to console
+// only the top entries of each table are included; top = 0 includes them all
+func SpaceReportFormatter(report SpaceReport, top int) string {
+	var outputStr string
+	outputStr += "# reclaimable\tduplicated\tfiles\tdir\n"
+	for i, dir := range report.Dirs {
+		if top > 0 && i >= top {
+			break
+		}
+		outputStr += HumanSize(dir.ReclaimableBytes) + "\t" +
+			HumanSize(dir.DuplicatedBytes) + "\t" +
+			strconv.Itoa(dir.NumFiles) + "\t" +
+			dir.Dir + "\n"
+	}
+	outputStr += "# total reclaimable: " + HumanSize(report.ReclaimableBytes) + "\n"
+
+	outputStr += "\n# shared\tfiles\tdir\tdir\n"
+	for i, pair := range report.Pairs {
+		if top > 0 && i >= top {
+			break
+		}
+		outputStr += HumanSize(pair.SharedBytes) + "\t" +
+			strconv.Itoa(pair.NumShared) + "\t" +
+			pair.DirA + "\t" +
+			pair.DirB + "\n"
+	}
+	return outputStr
+}
+
+// convert a comparison of two dirs to lines to be printed to console, with the kind of result at the start of each line
+// files in the first dir with more than one copy in the second dir get a line for each copy
+func CompareFormatter(report CompareReport, config FormatConfig) string {
+	var outputStr string
+	sizeColumn := func(size int64) string {
+		if config.Size {
+			return strconv.FormatInt(size, 10) + "\t"
+		}
+		return ""
+	}
+	for _, match := range report.Matches {
+		for _, entry := range match.B {
+			outputStr += "match\t" + sizeColumn(match.A.File.Size) + match.A.File.Path() + "\t" + entry.File.Path() + "\n"
+		}
+	}
+	for _, entry := range report.OnlyA {
+		outputStr += "only-a\t" + sizeColumn(entry.Size) + entry.Path() + "\n"
+	}
+	for _, entry := range report.OnlyB {
+		outputStr += "only-b\t" + sizeColumn(entry.Size) + entry.Path() + "\n"
+	}
+	for _, changed := range report.Changed {
+		outputStr += "changed\t" + sizeColumn(changed.A.Size) + changed.A.Path() + "\t" + sizeColumn(changed.B.Size) + changed.B.Path() + "\n"
+	}
+	return outputStr
+}
+
+// convert a checksum verification report to lines to be printed to console, with the kind of problem at the start of each line
+// files that passed are not included
+func VerifyFormatter(report VerifyReport) string {
+	var outputStr string
+	for _, mismatch := range report.Mismatched {
+		outputStr += "mismatch\t" + mismatch.Path + "\t" + mismatch.Want + "\t" + mismatch.Got + "\n"
+	}
+	for _, path := range report.Missing {
+		outputStr += "missing\t" + path + "\n"
+	}
+	for _, path := range report.Unreadable {
+		outputStr += "unreadable\t" + path + "\n"
+	}
+	for _, entry := range report.New {
+		outputStr += "new\t" + entry.Path() + "\n"
+	}
+	return outputStr
+}
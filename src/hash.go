@@ -1,12 +1,6 @@
 package finder
 
 import (
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"encoding/hex"
-	"github.com/cespare/xxhash" //https://pkg.go.dev/github.com/cespare/xxhash#section-readme
-	"hash"
 	"io"
 	"os"
 	"runtime"
@@ -15,11 +9,15 @@ import (
 
 // var numWorkers int = 4
 type HashConfig struct {
-	NumWorkers int
-	NumBytes   int64
-	Partial    bool
-	Algo       string
-	Verbose    bool //false by default
+	NumWorkers    int
+	NumBytes      int64
+	Partial       bool
+	Sample        string // which bytes to hash when Partial is set; SampleHead (default), SampleTail, SampleHeadTail, SampleSpread
+	NumBlocks     int    // number of blocks between the head and tail to hash with SampleSpread
+	Algo          string
+	Decompress    bool // hash the decompressed contents of .gz and .bz2 files instead of their raw bytes
+	NormalizeText bool // hash text files with line endings, trailing whitespace, and a UTF-8 BOM removed; binary files are hashed as is
+	Verbose       bool //false by default
 }
 
 type HashResult struct {
@@ -27,56 +25,48 @@ type HashResult struct {
 	Err   error
 }
 
-// get the md5 hash of an open file handle
+// get the hash of an open file handle using the algorithm named in the config
 // https://stackoverflow.com/questions/1761607/what-is-the-fastest-hash-algorithm-to-check-if-two-files-are-equal
-func getFileMD5(inputFile *os.File, config HashConfig) string {
-	algo := config.Algo
-	if algo == "" {
-		algo = "md5"
-	}
-
-	var hashWriter hash.Hash
-	switch {
-	case algo == "md5":
-		hashWriter = md5.New()
-	case algo == "sha1":
-		hashWriter = sha1.New()
-	case algo == "sha256":
-		hashWriter = sha256.New()
-	case algo == "xxhash":
-		hashWriter = xxhash.New()
-	default:
-		hashWriter = md5.New()
+func getFileMD5(inputFile *os.File, config HashConfig) (HashSum, error) {
+	newHash, err := GetHashAlgorithm(config.Algo)
+	if err != nil {
+		return HashSum{}, err
 	}
+	hashWriter := newHash()
 
 	// optionally hash only part of the file
 	if (config.Partial) && (config.NumBytes > 0) {
-		numBytesCopied, err := io.CopyN(hashWriter, inputFile, config.NumBytes)
+		info, err := inputFile.Stat()
 		if err != nil {
-			// if we are hashing n bytes then a lot of files will be too small so handle EOF
-			if err == io.EOF {
-				// dont print this it floods the terminal
-				// logger.Printf("Hashed %v bytes from file %v when %v bytes were wanted; continuing...\n", numBytesCopied, inputFile.Name(), config.NumBytes)
-			} else {
-				logger.Fatalf("Error encountered while hashing %v bytes from file: %v\n", numBytesCopied, err)
-			}
+			return HashSum{}, err
 		}
-
-	} else {
-		_, err := io.Copy(hashWriter, inputFile)
+		regions, err := config.sampleRegions(info.Size())
 		if err != nil {
-			logger.Fatalf("Error encountered while hashing file: %v\n", err)
+			return HashSum{}, err
+		}
+		// files smaller than the sample get hashed in full below
+		if regions != nil {
+			err = hashRegions(hashWriter, inputFile, regions)
+			if err != nil {
+				logger.Printf("Error encountered while hashing sampled bytes from file: %v\n", err)
+				return HashSum{}, err
+			}
+			return NewHashSum(hashWriter.Sum(nil)), nil
 		}
 	}
 
-	sum := hashWriter.Sum(nil)
-	hashStr := hex.EncodeToString(sum[:])
-	return hashStr
+	_, err = io.Copy(hashWriter, inputFile)
+	if err != nil {
+		logger.Printf("Error encountered while hashing file: %v\n", err)
+		return HashSum{}, err
+	}
+
+	return NewHashSum(hashWriter.Sum(nil)), nil
 }
 
 // handle the file opening and closing in order to get the file hash
 func GetFileHash(fileEntry FileEntry, config HashConfig) (FileHashEntry, error) {
-	file, err := os.Open(fileEntry.Path)
+	file, err := os.Open(fileEntry.Path())
 	// if file read permission is denied, skip this file
 	if os.IsPermission(err) {
 		// logger.Printf("WARNING: Skipping file that could not be opened due to permissions error: %v\n", err)
@@ -87,18 +77,77 @@ func GetFileHash(fileEntry FileEntry, config HashConfig) (FileHashEntry, error)
 		// logger.Printf("WARNING: Skipping file that could not be opened: %v\n", err)
 		return FileHashEntry{}, err
 	}
-	hash := getFileMD5(file, config)
+	var hash HashSum
+	var decompressed string
+	if config.Decompress {
+		decompressed = compressionFormat(fileEntry.Path())
+	}
+	if decompressed != "" {
+		hash, err = getDecompressedHash(file, decompressed, config)
+		// files that only look compressed get hashed as is
+		if err != nil {
+			decompressed = ""
+			_, err = file.Seek(0, io.SeekStart)
+		}
+	}
+
+	var normalized bool
+	if config.NormalizeText && decompressed == "" && err == nil {
+		normalized, err = isTextFile(file)
+	}
+	if err == nil && normalized {
+		hash, err = getNormalizedTextHash(file, config)
+	} else if err == nil && decompressed == "" {
+		hash, err = getFileMD5(file, config)
+	}
 	file.Close()
+	if err != nil {
+		return FileHashEntry{}, err
+	}
 
-	fileHashEntry := FileHashEntry{File: fileEntry, Hash: hash}
+	fileHashEntry := FileHashEntry{File: fileEntry, Hash: hash, Normalized: normalized, Decompressed: decompressed}
+	// let the user know that only part of the file was used
+	regions, _ := config.sampleRegions(fileEntry.Size)
+	if regions != nil && !normalized && decompressed == "" {
+		fileHashEntry.Sample = config.sampleLabel()
+	}
 	return fileHashEntry, err
 }
 
 // find files that have the same hash value
-func FindHashDupes(fileMap map[int64][]FileEntry, hashConfig HashConfig) map[string][]FileHashEntry {
-	hashesMap := map[string][]FileHashEntry{}
+func FindHashDupes(fileMap map[int64][]FileEntry, hashConfig HashConfig) map[HashSum][]FileHashEntry {
+	return FilterHashDupes(HashFiles(fileMap, hashConfig), hashConfig)
+}
+
+// get the groups of files from HashFiles that have more than one file with the same hash value
+func FilterHashDupes(hashesMap map[HashSum][]FileHashEntry, hashConfig HashConfig) map[HashSum][]FileHashEntry {
+	dupesMap := map[HashSum][]FileHashEntry{}
+	var numHashDupes int
+	for hash, entries := range hashesMap {
+		if len(entries) > 1 {
+			dupesMap[hash] = entries
+			numHashDupes += len(entries)
+		}
+	}
+
+	if hashConfig.Verbose {
+		logger.Printf("Found %v hash duplicates\n", numHashDupes)
+	}
+	return dupesMap
+}
+
+// hash every file and group them by hash value, including the files with a unique hash
+// files that cannot be opened or hashed are skipped with a warning
+func HashFiles(fileMap map[int64][]FileEntry, hashConfig HashConfig) map[HashSum][]FileHashEntry {
+	hashesMap := map[HashSum][]FileHashEntry{}
 	var numFilesHashed int
 
+	// dont bother opening any files if the algorithm is not valid
+	if _, err := GetHashAlgorithm(hashConfig.Algo); err != nil {
+		logger.Printf("ERROR: %v\n", err)
+		return map[HashSum][]FileHashEntry{}
+	}
+
 	// set up for concurrent parallel processing of file hashing
 	// https://stackoverflow.com/questions/71458290/how-to-batch-dealing-with-files-using-goroutine/71458664#71458664
 	var numWorkers int
@@ -119,7 +168,7 @@ func FindHashDupes(fileMap map[int64][]FileEntry, hashConfig HashConfig) map[str
 			defer wg.Done()
 			for fileEntry := range work {
 				if hashConfig.Verbose {
-					logger.Printf("Hashing %v\n", fileEntry.Path)
+					logger.Printf("Hashing %v\n", fileEntry.Path())
 				}
 				fileHashEntry, err := GetFileHash(fileEntry, hashConfig)
 				result := HashResult{Entry: fileHashEntry, Err: err}
@@ -161,7 +210,7 @@ func FindHashDupes(fileMap map[int64][]FileEntry, hashConfig HashConfig) map[str
 		}
 
 		if result.Err != nil {
-			logger.Printf("WARNING: Skipping file that could not be opened: %v\n", result.Err)
+			logger.Printf("WARNING: Skipping file that could not be opened or hashed: %v\n", result.Err)
 			continue
 		}
 		hashesMap[result.Entry.Hash] = append(hashesMap[result.Entry.Hash], result.Entry)
@@ -170,18 +219,5 @@ func FindHashDupes(fileMap map[int64][]FileEntry, hashConfig HashConfig) map[str
 	if hashConfig.Verbose {
 		logger.Printf("Hashed %v files\n", numFilesHashed)
 	}
-
-	dupesMap := map[string][]FileHashEntry{}
-	var numHashDupes int
-	for hash, entries := range hashesMap {
-		if len(entries) > 1 {
-			dupesMap[hash] = entries
-			numHashDupes += len(entries)
-		}
-	}
-
-	if hashConfig.Verbose {
-		logger.Printf("Found %v hash duplicates\n", numHashDupes)
-	}
-	return dupesMap
+	return hashesMap
 }
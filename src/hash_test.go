@@ -1,11 +1,29 @@
 package finder
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"hash"
+	"hash/fnv"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 )
 
+// parse a hex hash that is known to be valid
+func hashSum(hexStr string) HashSum {
+	sum, err := ParseHashSum(hexStr)
+	if err != nil {
+		panic(err)
+	}
+	return sum
+}
+
 // test cases for hashing algos
 func TestHash(t *testing.T) {
 	// setup test dirs & files
@@ -31,19 +49,100 @@ func TestHash(t *testing.T) {
 			config: HashConfig{Algo: "xxhash"},
 			want:   "b59acf3d21a6a54a",
 		},
+		"test_sha512": {
+			config: HashConfig{Algo: "sha512"},
+			want:   "9f7b9b4e40bf19565fa973ca014999214b71a635dcffc832af305c8abed4714db253de99ac6947c152ce65bcc5e4b25066ee408afa2d147abf2dd8dbb52000b7",
+		},
+		"test_blake2b": {
+			config: HashConfig{Algo: "blake2b"},
+			want:   "7a5d84968cdf2de7d0fff17d3899557a910410cffc17d0d4d9c50d2d127be516",
+		},
+		"test_crc32": {
+			config: HashConfig{Algo: "crc32"},
+			want:   "28ed7506",
+		},
+		"test_crc64": {
+			config: HashConfig{Algo: "crc64"},
+			want:   "5f92c51935a6d7c8",
+		},
+		"test_fnv64a": {
+			config: HashConfig{Algo: "fnv64a"},
+			want:   "a77b5bfb1d4eb7b5",
+		},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			tempfile1, _ := createTempFile(tempdir, "f.", "writes\n")
-			got := getFileMD5(tempfile1, tc.config)
-			if got != tc.want {
+			got, err := getFileMD5(tempfile1, tc.config)
+			if err != nil {
+				t.Errorf("got error %v", err)
+			}
+			if got.String() != tc.want {
 				t.Errorf("got %v is not the same as %v", got, tc.want)
 			}
 		})
 	}
 }
 
+// test cases for looking up and adding hashing algorithms
+func TestHashAlgorithmRegistry(t *testing.T) {
+	tempdir := t.TempDir()
+
+	t.Run("Reject unknown algorithm", func(t *testing.T) {
+		tempfile, _ := createTempFile(tempdir, "f.", "writes\n")
+		_, err := getFileMD5(tempfile, HashConfig{Algo: "sha9000"})
+		if err == nil {
+			t.Errorf("expected an error for unknown algorithm")
+		}
+	})
+
+	t.Run("Register a new algorithm", func(t *testing.T) {
+		err := RegisterHashAlgorithm("test-fnv32", func() hash.Hash { return fnv.New32() })
+		if err != nil {
+			t.Errorf("got error %v", err)
+		}
+		// registering the same name twice is not allowed
+		err = RegisterHashAlgorithm("test-fnv32", func() hash.Hash { return fnv.New32() })
+		if err == nil {
+			t.Errorf("expected an error for duplicate algorithm name")
+		}
+
+		tempfile, _ := createTempFile(tempdir, "f.", "writes\n")
+		got, err := getFileMD5(tempfile, HashConfig{Algo: "test-fnv32"})
+		if err != nil {
+			t.Errorf("got error %v", err)
+		}
+		want := "042d1669"
+		if got.String() != want {
+			t.Errorf("got %v is not the same as %v", got, want)
+		}
+	})
+}
+
+// test cases for storing hashes as raw bytes
+func TestHashSum(t *testing.T) {
+	sum, err := ParseHashSum("9D365F59076828ADD0B000414583CB33")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.String() != "9d365f59076828add0b000414583cb33" || sum.Len() != 16 || sum.IsZero() {
+		t.Errorf("got %v, %v bytes", sum, sum.Len())
+	}
+	// hashes with the same leading bytes but a different length are not the same
+	if sum == hashSum("9d365f59076828add0b000414583cb") || !hashSum("9d365f59076828add0b000414583cb").Less(sum) {
+		t.Errorf("hashes of different lengths should not be equal and the shorter one should sort first")
+	}
+	if !(HashSum{}).IsZero() {
+		t.Errorf("zero value should be empty")
+	}
+	for _, bad := range []string{"xyz", "abc"} {
+		if _, err := ParseHashSum(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
 // test case for hashing only a certain amount of bytes
 func TestHashN(t *testing.T) {
 	tempdir := t.TempDir()
@@ -52,9 +151,9 @@ func TestHashN(t *testing.T) {
 	t.Run("Hash only the file head", func(t *testing.T) {
 		// hash the entire file
 		hashConfig := HashConfig{}
-		got := getFileMD5(tempfile, hashConfig)
+		got, _ := getFileMD5(tempfile, hashConfig)
 		want := "d948f712fa329203f590e91cf6dd3e3e"
-		if got != want {
+		if got.String() != want {
 			t.Errorf("got %v is not the same as %v", got, want)
 		}
 
@@ -66,10 +165,210 @@ func TestHashN(t *testing.T) {
 		}
 
 		// hash only the first 10 bytes
-		got = getFileMD5(tempfile, HashConfig{Partial: true, NumBytes: 10})
+		got, _ = getFileMD5(tempfile, HashConfig{Partial: true, NumBytes: 10})
 		want = "a63c90cc3684ad8b0a2176a6a8fe9005"
-		if got != want {
+		if got.String() != want {
 			t.Errorf("got %v is not the same as %v", got, want)
 		}
 	})
 }
+
+// test cases for hashing sampled parts of the file
+func TestHashSample(t *testing.T) {
+	tempdir := t.TempDir()
+
+	// files with the same head but different tails
+	head := strings.Repeat("a", 50)
+	tempfile1, _ := createTempFile(tempdir, "f1.", head+strings.Repeat("b", 50))
+	tempfile2, _ := createTempFile(tempdir, "f2.", head+strings.Repeat("c", 50))
+	// file with the same head and tail as tempfile1 but a different middle
+	tempfile3, _ := createTempFile(tempdir, "f3.", head[:40]+strings.Repeat("z", 20)+strings.Repeat("b", 40))
+
+	tests := map[string]struct {
+		config    HashConfig
+		wantSame2 bool // tempfile1 and tempfile2 have the same hash
+		wantSame3 bool // tempfile1 and tempfile3 have the same hash
+	}{
+		"head": {
+			config:    HashConfig{Partial: true, NumBytes: 10},
+			wantSame2: true,
+			wantSame3: true,
+		},
+		"tail": {
+			config:    HashConfig{Partial: true, NumBytes: 10, Sample: SampleTail},
+			wantSame2: false,
+			wantSame3: true,
+		},
+		"head_tail": {
+			config:    HashConfig{Partial: true, NumBytes: 10, Sample: SampleHeadTail},
+			wantSame2: false,
+			wantSame3: true,
+		},
+		"spread": {
+			config:    HashConfig{Partial: true, NumBytes: 10, Sample: SampleSpread, NumBlocks: 3},
+			wantSame2: false,
+			wantSame3: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			hash1, err := getFileMD5(tempfile1, tc.config)
+			if err != nil {
+				t.Errorf("got error %v", err)
+			}
+			hash2, _ := getFileMD5(tempfile2, tc.config)
+			hash3, _ := getFileMD5(tempfile3, tc.config)
+			if (hash1 == hash2) != tc.wantSame2 {
+				t.Errorf("got hashes %v %v, wanted same: %v", hash1, hash2, tc.wantSame2)
+			}
+			if (hash1 == hash3) != tc.wantSame3 {
+				t.Errorf("got hashes %v %v, wanted same: %v", hash1, hash3, tc.wantSame3)
+			}
+		})
+	}
+
+	t.Run("Small files are hashed in full and not labeled as sampled", func(t *testing.T) {
+		config := HashConfig{Partial: true, NumBytes: 60, Sample: SampleHeadTail}
+		got := NewFileHashEntry(NewFileEntryFromPath(tempfile1.Name()), config)
+		want := NewFileHashEntry(NewFileEntryFromPath(tempfile1.Name()), HashConfig{})
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+
+		config = HashConfig{Partial: true, NumBytes: 10, Sample: SampleHeadTail}
+		got = NewFileHashEntry(NewFileEntryFromPath(tempfile1.Name()), config)
+		if got.Sample != "sampled:head-tail:10" {
+			t.Errorf("got sample label %q", got.Sample)
+		}
+	})
+
+	t.Run("Reject unknown sample mode", func(t *testing.T) {
+		_, err := getFileMD5(tempfile1, HashConfig{Partial: true, NumBytes: 10, Sample: "middle"})
+		if err == nil {
+			t.Errorf("expected an error for unknown sample mode")
+		}
+	})
+}
+
+// test cases for comparing text files with line endings, trailing whitespace, and BOMs normalized
+func TestNormalizeText(t *testing.T) {
+	tempdir := t.TempDir()
+	want, _ := createTempFile(tempdir, "want.", "echo hi\nexit 0\n")
+	wantHash := NewFileHashEntry(NewFileEntryFromPath(want.Name()), HashConfig{})
+
+	tests := map[string]struct {
+		contents string
+		wantSame bool
+	}{
+		"crlf":                {contents: "echo hi\r\nexit 0\r\n", wantSame: true},
+		"trailing_whitespace": {contents: "echo hi \t\nexit 0  \n", wantSame: true},
+		"bom":                 {contents: "\xef\xbb\xbfecho hi\nexit 0\n", wantSame: true},
+		"all":                 {contents: "\xef\xbb\xbfecho hi  \r\nexit 0\r\n", wantSame: true},
+		"leading_whitespace":  {contents: " echo hi\nexit 0\n", wantSame: false},
+		"missing_newline":     {contents: "echo hi\nexit 0", wantSame: false},
+		"blank_line":          {contents: "echo hi\n\nexit 0\n", wantSame: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, _ := createTempFile(tempdir, name+".", tc.contents)
+			got := NewFileHashEntry(NewFileEntryFromPath(file.Name()), HashConfig{NormalizeText: true})
+			if (got.Hash == wantHash.Hash) != tc.wantSame {
+				t.Errorf("got hash %v, want %v, wanted same: %v", got.Hash, wantHash.Hash, tc.wantSame)
+			}
+			if !got.Normalized {
+				t.Errorf("text file was not labeled as normalized")
+			}
+		})
+	}
+
+	t.Run("Binary files are hashed as is", func(t *testing.T) {
+		file, _ := createTempFile(tempdir, "binary.", "echo hi\r\n\x00")
+		got := NewFileHashEntry(NewFileEntryFromPath(file.Name()), HashConfig{NormalizeText: true})
+		raw := NewFileHashEntry(NewFileEntryFromPath(file.Name()), HashConfig{})
+		if got.Normalized || got.Hash != raw.Hash {
+			t.Errorf("got %+v, want %+v", got, raw)
+		}
+	})
+
+	t.Run("Find text dupes with different sizes", func(t *testing.T) {
+		dupesDir := createSubDir(tempdir, "dupes")
+		createTempFile(dupesDir, "unix.", "echo hi\nexit 0\n")
+		createTempFile(dupesDir, "windows.", "echo hi\r\nexit 0\r\n")
+		createTempFile(dupesDir, "other.", "echo bye\n")
+		dupes, _ := FindDupes(dupesDir, FindConfig{}, HashConfig{NormalizeText: true})
+		if len(dupes[wantHash.Hash]) != 2 || len(dupes) != 1 {
+			t.Errorf("got dupes %v", dupes)
+		}
+		dupes, _ = FindDupes(dupesDir, FindConfig{}, HashConfig{})
+		if len(dupes) != 0 {
+			t.Errorf("got dupes without normalizing %v", dupes)
+		}
+	})
+}
+
+// test cases for comparing compressed files by their decompressed contents
+func TestDecompress(t *testing.T) {
+	tempdir := t.TempDir()
+	wantHash := "d3b07384d113edec49eaa6238ad5ff00" // md5 of "foo\n"
+
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	gzipWriter.Write([]byte("foo\n"))
+	gzipWriter.Close()
+	// made with: printf 'foo\n' | bzip2 -9
+	bzipped := []byte{
+		0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x76, 0xf6, 0xb3, 0xe5, 0x00, 0x00,
+		0x00, 0xc1, 0x00, 0x00, 0x10, 0x01, 0x00, 0xa0, 0x00, 0x21, 0x98, 0x19, 0x84, 0x18, 0x5d, 0xc9,
+		0x14, 0xe1, 0x42, 0x41, 0xdb, 0xda, 0xcf, 0x94,
+	}
+	files := map[string][]byte{
+		"foo.txt":     []byte("foo\n"),
+		"foo.txt.gz":  gzipped.Bytes(),
+		"foo.txt.bz2": bzipped,
+		"fake.gz":     []byte("bar\n"),
+		"fake.txt":    []byte("bar\n"),
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(tempdir, name), contents, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	tests := map[string]struct {
+		name             string
+		wantHash         string
+		wantDecompressed string
+	}{
+		"gzip":         {name: "foo.txt.gz", wantHash: wantHash, wantDecompressed: CompressionGzip},
+		"bzip2":        {name: "foo.txt.bz2", wantHash: wantHash, wantDecompressed: CompressionBzip2},
+		"uncompressed": {name: "foo.txt", wantHash: wantHash},
+		"not_gzip":     {name: "fake.gz", wantHash: "c157a79031e1c40f85931829bc5fc552"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			entry := NewFileEntryFromPath(filepath.Join(tempdir, tc.name))
+			got := NewFileHashEntry(entry, HashConfig{Decompress: true})
+			if got.Hash.String() != tc.wantHash || got.Decompressed != tc.wantDecompressed {
+				t.Errorf("got %+v, want hash %v decompressed %q", got, tc.wantHash, tc.wantDecompressed)
+			}
+		})
+	}
+
+	t.Run("Find dupes across compression", func(t *testing.T) {
+		dupes, _ := FindDupes(tempdir, FindConfig{}, HashConfig{Decompress: true})
+		gotNames := []string{}
+		for _, entry := range dupes[hashSum(wantHash)] {
+			gotNames = append(gotNames, filepath.Base(entry.File.Path()))
+		}
+		sort.Strings(gotNames)
+		if diff := cmp.Diff([]string{"foo.txt", "foo.txt.bz2", "foo.txt.gz"}, gotNames); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+		if len(dupes) != 2 {
+			t.Errorf("got dupes %v", dupes)
+		}
+	})
+}
@@ -0,0 +1,353 @@
+package finder
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif" // register the decoders used by image.Decode
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"math/bits"
+	"os"
+	"sort"
+	"sync"
+)
+
+// perceptual hashing methods for finding near duplicate images
+const (
+	ImageHashAverage    = "ahash" // each bit is whether a pixel of an 8x8 thumbnail is brighter than the average; fastest, least robust
+	ImageHashDifference = "dhash" // each bit is whether a pixel of a 9x8 thumbnail is brighter than its neighbor
+	ImageHashPerceptual = "phash" // each bit is whether a low frequency DCT coefficient of a 32x32 thumbnail is above the median; most robust
+)
+
+// default number of differing bits between two 64 bit image hashes for the images to count as the same picture
+const DefaultImageDistance = 8
+
+// extensions of the files that get decoded in image mode
+var ImageExtensions = []string{"jpg", "jpeg", "png", "gif"}
+
+type ImageConfig struct {
+	Method      string // one of ImageHashAverage, ImageHashDifference, ImageHashPerceptual (default)
+	MaxDistance int    // max number of differing bits between two hashes for the images to be grouped together
+	NumWorkers  int
+	Verbose     bool
+}
+
+// image file entry with its perceptual hash
+type ImageHashEntry struct {
+	File     FileEntry
+	Hash     uint64
+	Width    int
+	Height   int
+	Distance int // number of bits that differ from the hash of the first image in its group
+}
+
+type ImageHashResult struct {
+	Entry ImageHashEntry
+	Err   error
+}
+
+// get the hash as a fixed width hex string
+func (entry ImageHashEntry) HashString() string {
+	return fmt.Sprintf("%016x", entry.Hash)
+}
+
+// number of bits that differ between two image hashes
+func HammingDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// shrink an image down to a w x h grid of grayscale values by averaging the pixels that fall in each cell
+func grayGrid(img image.Image, w int, h int) []float64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	grid := make([]float64, w*h)
+	if width == 0 || height == 0 {
+		return grid
+	}
+
+	// read the luma plane directly when we can since img.At is slow for large photos
+	gray := func(x int, y int) float64 {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+	}
+	switch src := img.(type) {
+	case *image.YCbCr:
+		gray = func(x int, y int) float64 { return float64(src.Y[src.YOffset(x, y)]) }
+	case *image.Gray:
+		gray = func(x int, y int) float64 { return float64(src.Pix[src.PixOffset(x, y)]) }
+	}
+
+	for ty := 0; ty < h; ty++ {
+		y0, y1 := ty*height/h, (ty+1)*height/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for tx := 0; tx < w; tx++ {
+			x0, x1 := tx*width/w, (tx+1)*width/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var sum float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					sum += gray(bounds.Min.X+x, bounds.Min.Y+y)
+				}
+			}
+			grid[ty*w+tx] = sum / float64((x1-x0)*(y1-y0))
+		}
+	}
+	return grid
+}
+
+// set a bit for each value that is above the threshold
+func thresholdBits(values []float64, threshold float64) uint64 {
+	var hash uint64
+	for i, value := range values {
+		if value > threshold {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+func averageHash(img image.Image) uint64 {
+	grid := grayGrid(img, 8, 8)
+	var sum float64
+	for _, value := range grid {
+		sum += value
+	}
+	return thresholdBits(grid, sum/float64(len(grid)))
+}
+
+func differenceHash(img image.Image) uint64 {
+	grid := grayGrid(img, 9, 8)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if grid[y*9+x+1] > grid[y*9+x] {
+				hash |= 1 << uint(y*8+x)
+			}
+		}
+	}
+	return hash
+}
+
+func perceptualHash(img image.Image) uint64 {
+	const size = 32
+	const keep = 8
+	grid := grayGrid(img, size, size)
+
+	// only the low frequencies are needed, so only compute the first few DCT coefficients
+	// of each row, then of each column of those
+	cosines := make([]float64, keep*size)
+	for u := 0; u < keep; u++ {
+		for x := 0; x < size; x++ {
+			cosines[u*size+x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * size))
+		}
+	}
+	rows := make([]float64, size*keep)
+	for y := 0; y < size; y++ {
+		for u := 0; u < keep; u++ {
+			var sum float64
+			for x := 0; x < size; x++ {
+				sum += grid[y*size+x] * cosines[u*size+x]
+			}
+			rows[y*keep+u] = sum
+		}
+	}
+	coefs := make([]float64, keep*keep)
+	for v := 0; v < keep; v++ {
+		for u := 0; u < keep; u++ {
+			var sum float64
+			for y := 0; y < size; y++ {
+				sum += rows[y*keep+u] * cosines[v*size+y]
+			}
+			coefs[v*keep+u] = sum
+		}
+	}
+
+	// the DC term is just the overall brightness so leave it out of the median
+	sorted := make([]float64, len(coefs)-1)
+	copy(sorted, coefs[1:])
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	return thresholdBits(coefs, median)
+}
+
+// get the perceptual hash of an image using the named method
+func HashImage(img image.Image, method string) (uint64, error) {
+	switch method {
+	case ImageHashPerceptual, "":
+		return perceptualHash(img), nil
+	case ImageHashDifference:
+		return differenceHash(img), nil
+	case ImageHashAverage:
+		return averageHash(img), nil
+	default:
+		return 0, fmt.Errorf("unknown image hash %q, valid options are: %s, %s, %s", method, ImageHashAverage, ImageHashDifference, ImageHashPerceptual)
+	}
+}
+
+// decode an image file and get its perceptual hash
+func GetImageHash(fileEntry FileEntry, method string) (ImageHashEntry, error) {
+	file, err := os.Open(fileEntry.Path())
+	if err != nil {
+		return ImageHashEntry{}, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return ImageHashEntry{}, fmt.Errorf("could not decode image %v: %v", fileEntry.Path(), err)
+	}
+	hash, err := HashImage(img, method)
+	if err != nil {
+		return ImageHashEntry{}, err
+	}
+	bounds := img.Bounds()
+	return ImageHashEntry{File: fileEntry, Hash: hash, Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
+
+// BK-tree of image hashes so that finding every hash within a distance does not need to check all pairs
+type bkNode struct {
+	index    int
+	hash     uint64
+	children map[int]*bkNode
+}
+
+func (node *bkNode) insert(index int, hash uint64) {
+	for {
+		distance := HammingDistance(node.hash, hash)
+		child, ok := node.children[distance]
+		if !ok {
+			node.children[distance] = &bkNode{index: index, hash: hash, children: map[int]*bkNode{}}
+			return
+		}
+		node = child
+	}
+}
+
+// get the indexes of every hash in the tree within maxDistance of the hash
+func (node *bkNode) search(hash uint64, maxDistance int, found []int) []int {
+	distance := HammingDistance(node.hash, hash)
+	if distance <= maxDistance {
+		found = append(found, node.index)
+	}
+	for childDistance, child := range node.children {
+		if childDistance >= distance-maxDistance && childDistance <= distance+maxDistance {
+			found = child.search(hash, maxDistance, found)
+		}
+	}
+	return found
+}
+
+// group entries whose hashes are within maxDistance of each other
+// groups are linked transitively, so two images in a group can be further apart than maxDistance
+// if there are other images in between
+func groupImageHashes(entries []ImageHashEntry, maxDistance int) [][]ImageHashEntry {
+	if len(entries) == 0 {
+		return [][]ImageHashEntry{}
+	}
+
+	// union find over the indexes of the entries
+	parents := make([]int, len(entries))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+
+	root := &bkNode{index: 0, hash: entries[0].Hash, children: map[int]*bkNode{}}
+	for i := 1; i < len(entries); i++ {
+		for _, j := range root.search(entries[i].Hash, maxDistance, nil) {
+			parents[find(j)] = find(i)
+		}
+		root.insert(i, entries[i].Hash)
+	}
+
+	groupsMap := map[int][]ImageHashEntry{}
+	for i, entry := range entries {
+		groupsMap[find(i)] = append(groupsMap[find(i)], entry)
+	}
+	groups := [][]ImageHashEntry{}
+	for _, group := range groupsMap {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool { return group[i].File.Path() < group[j].File.Path() })
+		for i := range group {
+			group[i].Distance = HammingDistance(group[0].Hash, group[i].Hash)
+		}
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0].File.Path() < groups[j][0].File.Path() })
+	return groups
+}
+
+// find groups of images that look the same, regardless of their size or encoding
+// only files with one of the ImageExtensions are decoded; files that cannot be decoded are skipped with a warning
+func FindImageDupes(fileMap map[int64][]FileEntry, config ImageConfig) [][]ImageHashEntry {
+	// dont bother decoding any files if the method is not valid
+	if _, err := HashImage(image.NewGray(image.Rect(0, 0, 1, 1)), config.Method); err != nil {
+		logger.Printf("ERROR: %v\n", err)
+		return [][]ImageHashEntry{}
+	}
+
+	numWorkers := config.NumWorkers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+
+	imageFilter := PathFilter{Extensions: ImageExtensions}
+	work := make(chan FileEntry)
+	results := make(chan ImageHashResult)
+	wg := sync.WaitGroup{}
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fileEntry := range work {
+				if config.Verbose {
+					logger.Printf("Hashing image %v\n", fileEntry.Path())
+				}
+				entry, err := GetImageHash(fileEntry, config.Method)
+				results <- ImageHashResult{Entry: entry, Err: err}
+			}
+		}()
+	}
+
+	go func() {
+		for _, entries := range fileMap {
+			for _, entry := range entries {
+				if imageFilter.Match(entry.Path()) {
+					work <- entry
+				}
+			}
+		}
+		close(work)
+		wg.Wait()
+		close(results)
+	}()
+
+	hashed := []ImageHashEntry{}
+	for result := range results {
+		if result.Err != nil {
+			logger.Printf("WARNING: Skipping image that could not be read: %v\n", result.Err)
+			continue
+		}
+		hashed = append(hashed, result.Entry)
+	}
+	// the order the workers finish in is random, so sort to keep the groups repeatable
+	sort.Slice(hashed, func(i, j int) bool { return hashed[i].File.Path() < hashed[j].File.Path() })
+
+	if config.Verbose {
+		logger.Printf("Hashed %v images\n", len(hashed))
+	}
+	return groupImageHashes(hashed, config.MaxDistance)
+}
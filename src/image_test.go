@@ -0,0 +1,107 @@
+package finder
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// draw the same picture at any resolution; a bright circle on a dark gradient, or a checkerboard
+func drawTestImage(width int, height int, checkers bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			fx, fy := float64(x)/float64(width), float64(y)/float64(height)
+			var v uint8
+			if checkers {
+				if (int(fx*4)+int(fy*4))%2 == 0 {
+					v = 230
+				}
+			} else {
+				v = uint8(80 * fx)
+				if (fx-0.6)*(fx-0.6)+(fy-0.4)*(fy-0.4) < 0.05 {
+					v = 220
+				}
+			}
+			img.Set(x, y, color.RGBA{v, v / 2, 255 - v, 255})
+		}
+	}
+	return img
+}
+
+func writeTestImage(path string, img image.Image) {
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	if filepath.Ext(path) == ".jpg" {
+		err = jpeg.Encode(file, img, &jpeg.Options{Quality: 60})
+	} else {
+		err = png.Encode(file, img)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// test cases for finding the same picture saved at different sizes and encodings
+func TestFindImageDupes(t *testing.T) {
+	tempdir := t.TempDir()
+	writeTestImage(filepath.Join(tempdir, "big.png"), drawTestImage(400, 300, false))
+	writeTestImage(filepath.Join(tempdir, "small.jpg"), drawTestImage(120, 90, false))
+	writeTestImage(filepath.Join(tempdir, "other.png"), drawTestImage(400, 300, true))
+	createTempFile(tempdir, "notes.*.txt", "foo\n")
+	// has an image extension but is not an image
+	if err := os.WriteFile(filepath.Join(tempdir, "broken.jpg"), []byte("foo\n"), 0644); err != nil {
+		log.Fatal(err)
+	}
+	fileMap, _ := FindFilesSizes(tempdir, FindConfig{})
+
+	for _, method := range []string{ImageHashAverage, ImageHashDifference, ImageHashPerceptual} {
+		t.Run(method, func(t *testing.T) {
+			config := ImageConfig{Method: method, MaxDistance: DefaultImageDistance, NumWorkers: 2}
+			groups := FindImageDupes(fileMap, config)
+			if len(groups) != 1 {
+				t.Fatalf("got %v groups, want 1: %v", len(groups), groups)
+			}
+			gotPaths := []string{}
+			for _, entry := range groups[0] {
+				gotPaths = append(gotPaths, filepath.Base(entry.File.Path()))
+			}
+			if diff := cmp.Diff([]string{"big.png", "small.jpg"}, gotPaths); diff != "" {
+				t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+			}
+			if groups[0][0].Width != 400 || groups[0][1].Height != 90 {
+				t.Errorf("got wrong image dimensions %+v", groups[0])
+			}
+		})
+	}
+
+	t.Run("Exact matches only", func(t *testing.T) {
+		writeTestImage(filepath.Join(tempdir, "copy.png"), drawTestImage(400, 300, true))
+		fileMap, _ := FindFilesSizes(tempdir, FindConfig{})
+		groups := FindImageDupes(fileMap, ImageConfig{MaxDistance: 0})
+		var found bool
+		for _, group := range groups {
+			if filepath.Base(group[0].File.Path()) == "copy.png" && filepath.Base(group[1].File.Path()) == "other.png" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("copy.png and other.png were not grouped together: %v", groups)
+		}
+	})
+
+	t.Run("Reject unknown methods", func(t *testing.T) {
+		if _, err := HashImage(drawTestImage(10, 10, false), "foo"); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
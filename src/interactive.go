@@ -0,0 +1,299 @@
+package finder
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const interactiveHelp = `choices:
+  2      keep copy 2 and delete the others
+  1,3    keep copies 1 and 3 and delete the others
+  l2     keep copy 2 and replace the others with links to it
+  s      skip this group and leave every copy alone
+  q      quit, leaving the rest of the groups alone
+add 'a' to the end of a choice (e.g. '2a', 'l1a', 'sa') to make the same choice for all the remaining
+groups that have one copy in each of the same dirs
+`
+
+type InteractiveConfig struct {
+	In               io.Reader
+	Out              io.Writer
+	LinkMode         string   // how to link copies when the 'l' choice is used; defaults to LinkHardlink
+	RelativeSymlinks bool     // use relative symlink targets when LinkMode is LinkSymlink
+	Journal          *Journal // optional journal to record deletes and links in so they can be undone
+	Algo             string   // hashing algorithm that was used for the duplicates, recorded in the journal
+}
+
+// counts of what happened during an interactive session
+type InteractiveSummary struct {
+	Deleted int
+	Linked  int
+	Skipped int // number of groups that were skipped
+	Errors  int
+}
+
+// a choice made by the user for a single group of duplicates
+type interactiveChoice struct {
+	Skip  bool
+	Quit  bool
+	Help  bool
+	Link  bool  // link the copies that are not kept instead of deleting them
+	Keep  []int // 0 based indexes of the copies to keep
+	Apply bool  // make the same choice for the remaining groups in the same dirs
+}
+
+// a choice that gets repeated for every group with one copy in each of the same dirs
+type interactiveRule struct {
+	Skip     bool
+	Link     bool
+	KeepDirs map[string]bool
+}
+
+// parse a line of user input into a choice for a group with numFiles copies
+func parseChoice(input string, numFiles int) (interactiveChoice, error) {
+	choice := interactiveChoice{}
+	input = strings.ToLower(strings.TrimSpace(input))
+	switch input {
+	case "?", "h", "help":
+		choice.Help = true
+		return choice, nil
+	case "q", "quit":
+		choice.Quit = true
+		return choice, nil
+	}
+
+	if strings.HasSuffix(input, "a") {
+		choice.Apply = true
+		input = strings.TrimSuffix(input, "a")
+	}
+	if input == "s" {
+		choice.Skip = true
+		return choice, nil
+	}
+	if strings.HasPrefix(input, "l") {
+		choice.Link = true
+		input = strings.TrimPrefix(input, "l")
+	}
+
+	seen := map[int]bool{}
+	for _, field := range strings.Split(input, ",") {
+		num, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || num < 1 || num > numFiles {
+			return choice, fmt.Errorf("invalid choice %q, enter '?' for help", field)
+		}
+		if !seen[num-1] {
+			choice.Keep = append(choice.Keep, num-1)
+			seen[num-1] = true
+		}
+	}
+	return choice, nil
+}
+
+// get the dirs of the copies in a group, or nil if any dir has more than one copy
+// since a choice made by dir could not tell those copies apart
+func groupDirs(entries []FileHashEntry) []string {
+	dirs := []string{}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		dir := filepath.Dir(entry.File.Path())
+		if seen[dir] {
+			return nil
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// describe a single copy of a duplicate file for the user to choose from
+func describeEntry(entry FileHashEntry) string {
+	info, err := os.Lstat(entry.File.Path())
+	if err != nil {
+		return entry.File.Path() + "\t(" + err.Error() + ")"
+	}
+	return entry.File.Path() + "\t" + info.ModTime().Format("2006-01-02 15:04:05") + "\t" + fileOwner(info)
+}
+
+// walk through each group of duplicates and ask the user which copies to keep
+// the copies that are not kept get deleted or linked to the first kept copy
+func InteractiveDupes(dupes map[HashSum][]FileHashEntry, config InteractiveConfig) (InteractiveSummary, error) {
+	summary := InteractiveSummary{}
+	if config.LinkMode == "" {
+		config.LinkMode = LinkHardlink
+	}
+	scanner := bufio.NewScanner(config.In)
+	rules := map[string]interactiveRule{}
+	hashes := sortedDupeHashes(dupes)
+	// the choices are only known one group at a time, so keep track of the dirs that have had copies replaced
+	// and the dirs that symlinks point into, and never let the two overlap
+	modified := map[string]bool{}
+	linkedInto := map[string]bool{}
+
+	for i, hash := range hashes {
+		entries := make([]FileHashEntry, len(dupes[hash]))
+		copy(entries, dupes[hash])
+		sort.Slice(entries, func(i, j int) bool { return entries[i].File.Path() < entries[j].File.Path() })
+		dirs := groupDirs(entries)
+		dirsKey := strings.Join(dirs, "\x00")
+
+		fmt.Fprintf(config.Out, "\n[%v/%v] %v\t%v bytes\t%v copies\n", i+1, len(hashes), hash, entries[0].File.Size, len(entries))
+		for j, entry := range entries {
+			fmt.Fprintf(config.Out, "  %v) %v\n", j+1, describeEntry(entry))
+		}
+
+		// use the choice that was already made for these dirs
+		var choice interactiveChoice
+		if rule, ok := rules[dirsKey]; ok && dirs != nil {
+			choice = interactiveChoice{Skip: rule.Skip, Link: rule.Link}
+			for j, entry := range entries {
+				if rule.KeepDirs[filepath.Dir(entry.File.Path())] {
+					choice.Keep = append(choice.Keep, j)
+				}
+			}
+			fmt.Fprintf(config.Out, "using the same choice as before for these dirs\n")
+		} else {
+			for {
+				fmt.Fprintf(config.Out, "keep which copies? [n, n,m, l<n>, s, q, ?]: ")
+				if !scanner.Scan() {
+					// no more input so leave the rest alone
+					fmt.Fprintln(config.Out)
+					return summary, scanner.Err()
+				}
+				var err error
+				choice, err = parseChoice(scanner.Text(), len(entries))
+				if err != nil {
+					fmt.Fprintln(config.Out, err)
+					continue
+				}
+				if choice.Help {
+					fmt.Fprint(config.Out, interactiveHelp)
+					continue
+				}
+				if choice.Apply && dirs == nil {
+					fmt.Fprintf(config.Out, "cannot repeat this choice since some dirs have more than one copy\n")
+					continue
+				}
+				break
+			}
+		}
+
+		if choice.Quit {
+			return summary, nil
+		}
+		if choice.Apply {
+			rule := interactiveRule{Skip: choice.Skip, Link: choice.Link, KeepDirs: map[string]bool{}}
+			for _, j := range choice.Keep {
+				rule.KeepDirs[filepath.Dir(entries[j].File.Path())] = true
+			}
+			rules[dirsKey] = rule
+		}
+		if choice.Skip || len(choice.Keep) == 0 {
+			summary.Skipped += 1
+			continue
+		}
+
+		err := applyChoice(entries, choice, config, modified, linkedInto, &summary)
+		if err != nil {
+			return summary, err
+		}
+	}
+	return summary, nil
+}
+
+// delete or link the copies that the user did not choose to keep
+// each copy is compared byte for byte with the kept copy first since the hashes may not cover the whole file
+func applyChoice(entries []FileHashEntry, choice interactiveChoice, config InteractiveConfig, modified map[string]bool, linkedInto map[string]bool, summary *InteractiveSummary) error {
+	keep := entries[choice.Keep[0]]
+	kept := map[int]bool{}
+	for _, j := range choice.Keep {
+		kept[j] = true
+	}
+
+	action := ActionDelete
+	if choice.Link {
+		var err error
+		action, err = linkAction(config.LinkMode)
+		if err != nil {
+			return err
+		}
+	}
+	symlink := choice.Link && config.LinkMode == LinkSymlink
+	keptDir, err := filepath.Abs(filepath.Dir(keep.File.Path()))
+	if err != nil {
+		return err
+	}
+
+	for j, entry := range entries {
+		if kept[j] {
+			continue
+		}
+		dir, err := filepath.Abs(filepath.Dir(entry.File.Path()))
+		if err == nil {
+			err = checkReplaceable(keep, entry, keptDir, dir, symlink, modified, linkedInto)
+		}
+		var journalEntry JournalEntry
+		if err == nil {
+			journalEntry, err = NewJournalEntry(action, entry, keep.File.Path(), config.Algo)
+		}
+		if err == nil {
+			if err := config.Journal.record(journalEntry); err != nil {
+				return err
+			}
+			if choice.Link {
+				linkConfig := LinkConfig{Mode: config.LinkMode, RelativeSymlinks: config.RelativeSymlinks}
+				err = LinkFile(keep.File.Path(), entry.File.Path(), linkConfig)
+			} else {
+				err = os.Remove(entry.File.Path())
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(config.Out, "could not %v %v: %v\n", action, entry.File.Path(), err)
+			summary.Errors += 1
+			continue
+		}
+		if dir != keptDir {
+			modified[dir] = true
+			if symlink {
+				linkedInto[keptDir] = true
+			}
+		}
+
+		if choice.Link {
+			fmt.Fprintf(config.Out, "linked %v -> %v\n", entry.File.Path(), keep.File.Path())
+			summary.Linked += 1
+		} else {
+			fmt.Fprintf(config.Out, "deleted %v\n", entry.File.Path())
+			summary.Deleted += 1
+		}
+	}
+	return nil
+}
+
+// check that a copy in dir can be deleted or linked to the kept copy in keptDir
+func checkReplaceable(keep FileHashEntry, entry FileHashEntry, keptDir string, dir string, symlink bool, modified map[string]bool, linkedInto map[string]bool) error {
+	if symlink && unsafeSymlink(modified, keptDir, entry.File.Path()) {
+		return fmt.Errorf("refusing to symlink to %v because files in %v were also replaced", keep.File.Path(), keptDir)
+	}
+	if dir != keptDir {
+		for linkedDir := range linkedInto {
+			if inDir(linkedDir, dir) {
+				return fmt.Errorf("refusing to replace it because symlinks were made that point into %v", linkedDir)
+			}
+		}
+	}
+	same, err := sameContents(keep.File.Path(), entry.File.Path())
+	if err != nil {
+		return err
+	}
+	if !same {
+		return fmt.Errorf("contents differ from %v", keep.File.Path())
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package finder
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// default path for the journal of destructive actions
+const DefaultJournalPath = "dupefinder-journal.jsonl"
+
+// actions that get recorded in the journal
+const (
+	ActionMove     = "move"     // file was moved to Target
+	ActionDelete   = "delete"   // file was deleted, Target is the kept copy with the same contents
+	ActionHardlink = "hardlink" // file was replaced with a hardlink to Target
+	ActionSymlink  = "symlink"  // file was replaced with a symlink to Target
+	ActionReflink  = "reflink"  // file was made to share its data extents with Target
+	ActionRemove   = "remove"   // empty file, empty dir, or broken symlink was removed; Target is what the symlink pointed to
+)
+
+// a single destructive action, with enough information about the original file to put it back
+type JournalEntry struct {
+	Time    time.Time   `json:"time"`
+	Action  string      `json:"action"`
+	Path    string      `json:"path"`   // original path of the file that was acted on
+	Target  string      `json:"target"` // where the file was moved to, or the kept copy that it duplicated
+	Hash    string      `json:"hash"`
+	Algo    string      `json:"algo,omitempty"`
+	Sample  string      `json:"sample,omitempty"` // set if Hash was made from only part of the file
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mtime"`
+}
+
+// append-only log of destructive actions that can be replayed in reverse by UndoJournal
+// safe to use from multiple goroutines
+type Journal struct {
+	file  *os.File
+	mutex sync.Mutex
+}
+
+// open a journal for appending, creating it if needed
+func OpenJournal(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{file: file}, nil
+}
+
+func (journal *Journal) Close() error {
+	return journal.file.Close()
+}
+
+// write an entry to the journal; entries are flushed to disk right away so that
+// the journal is still useful if the program gets interrupted
+func (journal *Journal) Record(entry JournalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	_, err = journal.file.Write(line)
+	if err != nil {
+		return err
+	}
+	return journal.file.Sync()
+}
+
+// record an action on a file in the journal, if there is one
+// this is a no-op for a nil journal so that callers do not need to check
+func (journal *Journal) record(entry JournalEntry) error {
+	if journal == nil {
+		return nil
+	}
+	return journal.Record(entry)
+}
+
+// make a journal entry from the current state of a file; call this and record the entry before acting on the file,
+// so that nothing gets changed without a journal entry; undo skips the entries whose action never happened
+// the paths are made absolute so that the journal can be undone from any dir
+func NewJournalEntry(action string, entry FileHashEntry, target string, algo string) (JournalEntry, error) {
+	info, err := os.Lstat(entry.File.Path())
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	path, err := filepath.Abs(entry.File.Path())
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	target, err = filepath.Abs(target)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	journalEntry := JournalEntry{
+		Time:    time.Now(),
+		Action:  action,
+		Path:    path,
+		Target:  target,
+		Hash:    entry.Hash.String(),
+		Algo:    algo,
+		Sample:  entry.Sample,
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+	}
+	if journalEntry.Algo == "" {
+		journalEntry.Algo = DefaultHashAlgorithm
+	}
+	return journalEntry, nil
+}
+
+// read all the entries from a journal file in the order they were written
+func ReadJournal(path string) ([]JournalEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readJournal(file)
+}
+
+func readJournal(reader io.Reader) ([]JournalEntry, error) {
+	entries := []JournalEntry{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum += 1
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		err := json.Unmarshal(line, &entry)
+		if err != nil {
+			return entries, fmt.Errorf("could not read journal line %v: %v", lineNum, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+type UndoConfig struct {
+	DryRun  bool // only report what would be undone
+	Verbose bool
+}
+
+// replay a journal in reverse to put files back the way they were
+// moved files are moved back; deleted and linked files are restored as independent copies of the kept file
+// entries that cannot be undone are skipped with a warning; returns the entries that were undone
+func UndoJournal(path string, config UndoConfig) ([]JournalEntry, error) {
+	undone := []JournalEntry{}
+	entries, err := ReadJournal(path)
+	if err != nil {
+		return undone, err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if config.Verbose || config.DryRun {
+			logger.Printf("Undo %v %v (target %v)\n", entry.Action, entry.Path, entry.Target)
+		}
+		if config.DryRun {
+			undone = append(undone, entry)
+			continue
+		}
+		err := UndoEntry(entry)
+		if err != nil {
+			logger.Printf("WARNING: Could not undo %v of %v: %v\n", entry.Action, entry.Path, err)
+			continue
+		}
+		undone = append(undone, entry)
+	}
+	return undone, nil
+}
+
+// undo a single journal entry
+func UndoEntry(entry JournalEntry) error {
+	switch entry.Action {
+	case ActionMove:
+		return undoMove(entry)
+	case ActionDelete, ActionHardlink, ActionSymlink, ActionReflink:
+		return restoreFromTarget(entry)
+	case ActionRemove:
+		return undoRemove(entry)
+	default:
+		return fmt.Errorf("unknown action %q", entry.Action)
+	}
+}
+
+// move a file back to where it came from
+func undoMove(entry JournalEntry) error {
+	if _, err := os.Lstat(entry.Path); err == nil {
+		return fmt.Errorf("original path already exists")
+	}
+	err := os.MkdirAll(filepath.Dir(entry.Path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(entry.Target, entry.Path)
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(entry.Path, entry.ModTime, entry.ModTime)
+}
+
+// put back an empty file, empty dir, or broken symlink
+func undoRemove(entry JournalEntry) error {
+	if _, err := os.Lstat(entry.Path); err == nil {
+		return fmt.Errorf("original path already exists")
+	}
+	err := os.MkdirAll(filepath.Dir(entry.Path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	switch {
+	case entry.Mode.IsDir():
+		err = os.Mkdir(entry.Path, entry.Mode.Perm())
+	case entry.Mode&os.ModeSymlink != 0:
+		// the times of a symlink cannot be set portably so leave them
+		return os.Symlink(entry.Target, entry.Path)
+	default:
+		var file *os.File
+		file, err = os.OpenFile(entry.Path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, entry.Mode.Perm())
+		if err == nil {
+			err = file.Close()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(entry.Path, entry.Mode.Perm())
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(entry.Path, entry.ModTime, entry.ModTime)
+}
+
+// put an independent copy of the kept file back at the original path
+// used for files that were deleted or replaced with links
+func restoreFromTarget(entry JournalEntry) error {
+	info, err := os.Lstat(entry.Path)
+	if entry.Action == ActionDelete && err == nil {
+		return fmt.Errorf("original path already exists")
+	}
+	if entry.Action == ActionSymlink && err == nil && info.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("original path is no longer a symlink")
+	}
+	// the original path could be a new file by now, so only replace it if it is still the link
+	if entry.Action == ActionHardlink && err == nil {
+		target, err := os.Stat(entry.Target)
+		if err != nil {
+			return err
+		}
+		if !os.SameFile(info, target) {
+			return fmt.Errorf("original path is no longer a hardlink to %v", entry.Target)
+		}
+	}
+
+	// make sure the kept copy still has the contents we expect before copying it
+	target, err := os.Stat(entry.Target)
+	if err != nil {
+		return err
+	}
+	if target.Size() != entry.Size {
+		return fmt.Errorf("kept file %v has changed size", entry.Target)
+	}
+	if entry.Sample == "" && entry.Hash != "" {
+		hashEntry, err := GetFileHash(NewFileEntryFromPathInfo(entry.Target, target), HashConfig{Algo: entry.Algo})
+		if err != nil {
+			return err
+		}
+		if hashEntry.Hash.String() != entry.Hash {
+			return fmt.Errorf("kept file %v has changed contents", entry.Target)
+		}
+	}
+	// reflinks are independent files that may have been edited since, so only replace one that still has the same contents
+	if entry.Action == ActionReflink {
+		if _, err := os.Lstat(entry.Path); err == nil {
+			same, err := sameContents(entry.Path, entry.Target)
+			if err != nil {
+				return err
+			}
+			if !same {
+				return fmt.Errorf("original path has changed contents since it was reflinked")
+			}
+		}
+	}
+
+	// write the copy next to the original path then swap it into place
+	// so that the original path is never missing if something goes wrong
+	err = os.MkdirAll(filepath.Dir(entry.Path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	tempPath, err := copyToTemp(entry.Target, filepath.Dir(entry.Path))
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tempPath, entry.Mode.Perm())
+	if err == nil {
+		err = os.Chtimes(tempPath, entry.ModTime, entry.ModTime)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, entry.Path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return err
+}
+
+// copy a file into a new temp file in the dir and return the temp file's path
+func copyToTemp(src string, dir string) (string, error) {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer srcFile.Close()
+
+	tempFile, err := os.CreateTemp(dir, ".dupefinder-")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tempFile, srcFile)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return "", err
+	}
+	return tempFile.Name(), nil
+}
@@ -0,0 +1,201 @@
+package finder
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// a symlink whose target does not exist
+type BrokenSymlink struct {
+	Path   string
+	Target string
+}
+
+// files and dirs that take up space in listings without holding any data
+type JunkReport struct {
+	EmptyFiles     []FileEntry
+	EmptyDirs      []string // dirs with no files anywhere below them, parents before their children; never includes the search dir
+	BrokenSymlinks []BrokenSymlink
+}
+
+type JunkCleanConfig struct {
+	Journal *Journal // optional journal to record the removals in so they can be undone
+	Verbose bool
+}
+
+// find empty files, empty dirs, and broken symlinks in the directory tree
+// the dir and file filters in the config are used the same way as FindFilesSizes, except for the size limits;
+// dirs that get skipped or cannot be read are never reported as empty since we cannot tell what is in them
+func FindJunk(dirPath string, config FindConfig) (JunkReport, error) {
+	report := JunkReport{}
+	dirPath = filepath.Clean(dirPath)
+	dirs := []string{}
+	hasContent := map[string]bool{}
+
+	// mark a path and all the dirs above it as holding something
+	markContent := func(path string) {
+		for !hasContent[path] {
+			hasContent[path] = true
+			parent := filepath.Dir(path)
+			if path == dirPath || parent == path {
+				return
+			}
+			path = parent
+		}
+	}
+
+	err := filepath.Walk(dirPath, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			logger.Printf("Skipping path that could not be read %q: %v\n", path, err)
+			markContent(path)
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() &&
+			containsStr(config.SkipDirs, info.Name()) ||
+			containsStr(config.SkipDirs, path) {
+			markContent(path)
+			return filepath.SkipDir
+		}
+
+		relPath, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			relPath = path
+		}
+		depth := pathDepth(relPath)
+
+		if info.IsDir() {
+			if config.skipDir(depth, info.Name()) {
+				markContent(path)
+				return filepath.SkipDir
+			}
+			if path != dirPath {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+
+		// anything that is not a dir counts as something in the dirs above it, even if it is junk itself
+		markContent(filepath.Dir(path))
+		if !config.passDepth(depth, info.Name()) || !config.passModTime(info.ModTime()) || !config.passPathFilters(relPath) {
+			return nil
+		}
+		switch {
+		case info.Mode().IsRegular() && info.Size() == 0:
+			report.EmptyFiles = append(report.EmptyFiles, NewFileEntryFromPathInfo(path, info))
+		case info.Mode()&os.ModeSymlink != 0:
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				target, _ := os.Readlink(path)
+				report.BrokenSymlinks = append(report.BrokenSymlinks, BrokenSymlink{Path: path, Target: target})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for _, dir := range dirs {
+		if !hasContent[dir] {
+			report.EmptyDirs = append(report.EmptyDirs, dir)
+		}
+	}
+
+	if config.Verbose {
+		logger.Printf("Found %v empty files, %v empty dirs, %v broken symlinks\n", len(report.EmptyFiles), len(report.EmptyDirs), len(report.BrokenSymlinks))
+	}
+	return report, nil
+}
+
+// check that a single piece of junk is still junk and make the journal entry for removing it
+func junkJournalEntry(path string, wantMode os.FileMode) (JournalEntry, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	var target string
+	switch {
+	case wantMode.IsDir():
+		if !info.IsDir() {
+			return JournalEntry{}, fmt.Errorf("no longer a dir")
+		}
+	case wantMode&os.ModeSymlink != 0:
+		if info.Mode()&os.ModeSymlink == 0 {
+			return JournalEntry{}, fmt.Errorf("no longer a symlink")
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			return JournalEntry{}, fmt.Errorf("symlink target exists now")
+		}
+		target, err = os.Readlink(path)
+		if err != nil {
+			return JournalEntry{}, err
+		}
+	default:
+		if !info.Mode().IsRegular() || info.Size() != 0 {
+			return JournalEntry{}, fmt.Errorf("no longer an empty file")
+		}
+	}
+
+	// the target of a symlink is kept as it was written, since a relative one is relative to the link
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	return JournalEntry{
+		Time:    time.Now(),
+		Action:  ActionRemove,
+		Path:    absPath,
+		Target:  target,
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+// remove the empty files, broken symlinks, and empty dirs in a report
+// files are removed first and dirs are removed deepest first; anything that is no longer junk, or that
+// cannot be removed, is skipped with a warning; returns the paths that were removed
+func CleanJunk(report JunkReport, config JunkCleanConfig) ([]string, error) {
+	removed := []string{}
+	remove := func(path string, mode os.FileMode) error {
+		journalEntry, err := junkJournalEntry(path, mode)
+		if err != nil {
+			logger.Printf("WARNING: Could not remove %v: %v\n", path, err)
+			return nil
+		}
+		if err := config.Journal.record(journalEntry); err != nil {
+			return err
+		}
+		// os.Remove refuses to remove dirs that are not empty, so nothing that showed up since the search gets lost
+		if err := os.Remove(path); err != nil {
+			logger.Printf("WARNING: Could not remove %v: %v\n", path, err)
+			return nil
+		}
+		if config.Verbose {
+			logger.Printf("Removed %v\n", path)
+		}
+		removed = append(removed, path)
+		return nil
+	}
+
+	for _, entry := range report.EmptyFiles {
+		if err := remove(entry.Path(), 0); err != nil {
+			return removed, err
+		}
+	}
+	for _, link := range report.BrokenSymlinks {
+		if err := remove(link.Path, os.ModeSymlink); err != nil {
+			return removed, err
+		}
+	}
+	for i := len(report.EmptyDirs) - 1; i >= 0; i-- {
+		if err := remove(report.EmptyDirs[i], os.ModeDir); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
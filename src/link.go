@@ -0,0 +1,229 @@
+package finder
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ways to replace the redundant copies of duplicate files
+const (
+	LinkHardlink = "hardlink" // replace the copy with a hardlink to the kept file
+	LinkReflink  = "reflink"  // share the data extents of the kept file; the copy stays an independent file (Linux, btrfs/XFS)
+	LinkSymlink  = "symlink"  // replace the copy with a symlink to the kept file; works across filesystems
+)
+
+// returned when the filesystem or platform does not support sharing data extents between files
+var ErrReflinkUnsupported = errors.New("reflinks are not supported on this filesystem")
+
+type LinkConfig struct {
+	Mode             string   // one of LinkHardlink, LinkReflink, LinkSymlink
+	RelativeSymlinks bool     // make symlinks point to the kept file with a relative path instead of an absolute path
+	Journal          *Journal // optional journal to record the links in so they can be undone
+	Algo             string   // hashing algorithm that was used for the duplicates, recorded in the journal
+	Verbose          bool
+}
+
+// result of linking a single redundant copy to the kept file
+type LinkResult struct {
+	Entry FileHashEntry // the redundant copy
+	Kept  string        // path of the file that was kept
+	Err   error         // set if the file could not be linked
+}
+
+// get the journal action that matches the link mode
+func linkAction(mode string) (string, error) {
+	switch mode {
+	case LinkHardlink:
+		return ActionHardlink, nil
+	case LinkReflink:
+		return ActionReflink, nil
+	case LinkSymlink:
+		return ActionSymlink, nil
+	default:
+		return "", fmt.Errorf("unknown link mode %q, valid options are: %s, %s, %s", mode, LinkHardlink, LinkReflink, LinkSymlink)
+	}
+}
+
+// replace the file at path with a hardlink to the kept file
+// the link is made under a temporary name first then renamed over the path
+// so the path is never missing if something goes wrong
+func hardlinkFile(kept string, path string) error {
+	tempPath := filepath.Join(filepath.Dir(path), ".dupefinder-link-"+filepath.Base(path))
+	err := os.Link(kept, tempPath)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tempPath, path)
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return err
+}
+
+// get the path that a symlink at path should contain in order to point to the kept file
+func symlinkTarget(kept string, path string, relative bool) (string, error) {
+	absKept, err := filepath.Abs(kept)
+	if err != nil {
+		return "", err
+	}
+	if !relative {
+		return absKept, nil
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(filepath.Dir(absPath), absKept)
+}
+
+// replace the file at path with a symlink to the kept file, the same way as hardlinkFile
+func symlinkFile(kept string, path string, relative bool) error {
+	target, err := symlinkTarget(kept, path, relative)
+	if err != nil {
+		return err
+	}
+	tempPath := filepath.Join(filepath.Dir(path), ".dupefinder-link-"+filepath.Base(path))
+	err = os.Symlink(target, tempPath)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tempPath, path)
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return err
+}
+
+// link a single redundant copy to the kept file using the mode in the config
+func LinkFile(kept string, path string, config LinkConfig) error {
+	keptInfo, err := os.Stat(kept)
+	if err != nil {
+		return err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("not a regular file: %v", path)
+	}
+
+	switch config.Mode {
+	case LinkHardlink:
+		if os.SameFile(keptInfo, info) {
+			return fmt.Errorf("already a hardlink to %v", kept)
+		}
+		return hardlinkFile(kept, path)
+	case LinkReflink:
+		return reflinkFile(kept, path)
+	case LinkSymlink:
+		if os.SameFile(keptInfo, info) {
+			return fmt.Errorf("same file as %v", kept)
+		}
+		return symlinkFile(kept, path, config.RelativeSymlinks)
+	default:
+		_, err := linkAction(config.Mode)
+		return err
+	}
+}
+
+// get the set of dirs that hold redundant copies which are going to be replaced by links into some other dir
+// copies that get linked to a kept file in their own dir are not counted since they do not depend on any other dir
+func modifiedDirs(dupes map[HashSum][]FileHashEntry) map[string]bool {
+	dirs := map[string]bool{}
+	for _, hash := range sortedDupeHashes(dupes) {
+		keep, redundant := SplitKeeper(dupes[hash])
+		keptDir, err := filepath.Abs(filepath.Dir(keep.File.Path()))
+		if err != nil {
+			continue
+		}
+		for _, entry := range redundant {
+			dir, err := filepath.Abs(filepath.Dir(entry.File.Path()))
+			if err == nil && dir != keptDir {
+				dirs[dir] = true
+			}
+		}
+	}
+	return dirs
+}
+
+// check if a symlink at path pointing to a kept file in keptDir would point into one of the unsafe dirs
+// or into a dir below one of them, since the target path goes through the unsafe dir either way
+func unsafeSymlink(unsafeDirs map[string]bool, keptDir string, path string) bool {
+	unsafe := false
+	for dir := range unsafeDirs {
+		if inDir(keptDir, dir) {
+			unsafe = true
+			break
+		}
+	}
+	if !unsafe {
+		return false
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	return err != nil || dir != keptDir
+}
+
+// link the redundant copies from every duplicate group to the kept copy
+// files that cannot be linked are skipped; check LinkResult.Err for the reason
+func LinkDupes(dupes map[HashSum][]FileHashEntry, config LinkConfig) ([]LinkResult, error) {
+	results := []LinkResult{}
+	action, err := linkAction(config.Mode)
+	if err != nil {
+		return results, err
+	}
+
+	// symlinks break if their target gets replaced or cleaned up later, so refuse to point
+	// them into any dir that is having its own files replaced during this run
+	var unsafeDirs map[string]bool
+	if config.Mode == LinkSymlink {
+		unsafeDirs = modifiedDirs(dupes)
+	}
+
+	for _, hash := range sortedDupeHashes(dupes) {
+		keep, redundant := SplitKeeper(dupes[hash])
+		keptDir, err := filepath.Abs(filepath.Dir(keep.File.Path()))
+		if err != nil {
+			return results, err
+		}
+		for _, entry := range redundant {
+			result := LinkResult{Entry: entry, Kept: keep.File.Path()}
+			if unsafeSymlink(unsafeDirs, keptDir, entry.File.Path()) {
+				result.Err = fmt.Errorf("refusing to symlink to %v because files in %v are also being replaced", keep.File.Path(), keptDir)
+				results = append(results, result)
+				continue
+			}
+			// a weak hash like crc32 can match files that differ, so make sure nothing is lost before replacing it
+			same, err := sameContents(keep.File.Path(), entry.File.Path())
+			if err == nil && !same {
+				err = fmt.Errorf("contents differ from %v", keep.File.Path())
+			}
+			if err != nil {
+				result.Err = err
+				results = append(results, result)
+				continue
+			}
+			journalEntry, err := NewJournalEntry(action, entry, keep.File.Path(), config.Algo)
+			if err != nil {
+				result.Err = err
+				results = append(results, result)
+				continue
+			}
+			if err := config.Journal.record(journalEntry); err != nil {
+				return results, err
+			}
+			if err := LinkFile(keep.File.Path(), entry.File.Path(), config); err != nil {
+				result.Err = err
+				results = append(results, result)
+				continue
+			}
+			if config.Verbose {
+				logger.Printf("Linked %v to %v with %v\n", entry.File.Path(), keep.File.Path(), config.Mode)
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
@@ -16,10 +16,10 @@ func TestLargeFileHandling(t *testing.T) {
 		fileEntries := []FileEntry{}
 		for _, v := range []int64{3e8, 4e8, 1e8, 1e7, 4e5, 8e4, 3e8, 4e8} {
 			tempfile, info := createLargeFile(tempdir, v)
-			fileEntries = append(fileEntries, FileEntry{Path: tempfile.Name(), Size: info.Size(), Name: info.Name()})
-			fileEntries = append(fileEntries, FileEntry{Path: tempfile.Name(), Size: info.Size(), Name: info.Name()})
-			fileEntries = append(fileEntries, FileEntry{Path: tempfile.Name(), Size: info.Size(), Name: info.Name()})
-			fileEntries = append(fileEntries, FileEntry{Path: tempfile.Name(), Size: info.Size(), Name: info.Name()})
+			fileEntries = append(fileEntries, NewFileEntryFromPathInfo(tempfile.Name(), info))
+			fileEntries = append(fileEntries, NewFileEntryFromPathInfo(tempfile.Name(), info))
+			fileEntries = append(fileEntries, NewFileEntryFromPathInfo(tempfile.Name(), info))
+			fileEntries = append(fileEntries, NewFileEntryFromPathInfo(tempfile.Name(), info))
 		}
 		// time.Sleep(1 * time.Second)
 		// log.Printf("\n\n>>>>> %v\n", fileEntries)
@@ -0,0 +1,85 @@
+package finder
+
+import (
+	"sort"
+	"strconv"
+)
+
+// a file name that appears in more than one place with different contents
+type NameGroup struct {
+	Name     string
+	Entries  []FileHashEntry // every file with the name, sorted by path; Hash is only set when another file with the name has the same size
+	Versions []int           // version number of each entry; entries with the same version have the same contents
+}
+
+// get the number of different versions of the file in the group
+func (group NameGroup) NumVersions() int {
+	var numVersions int
+	for _, version := range group.Versions {
+		if version > numVersions {
+			numVersions = version
+		}
+	}
+	return numVersions
+}
+
+// find file names that appear in more than one dir with different sizes or hashes, such as config files
+// and documents that were edited in different places
+// files are only hashed when another file with the same name has the same size
+func FindSameNameFiles(fileMap map[int64][]FileEntry, hashConfig HashConfig) []NameGroup {
+	nameMap := map[string][]FileEntry{}
+	for _, entries := range fileMap {
+		for _, entry := range entries {
+			nameMap[entry.Name] = append(nameMap[entry.Name], entry)
+		}
+	}
+
+	// files that have the same name and size need to be hashed to tell if they differ
+	toHash := map[int64][]FileEntry{}
+	for name, entries := range nameMap {
+		if len(entries) < 2 {
+			delete(nameMap, name)
+			continue
+		}
+		sizeCounts := map[int64]int{}
+		for _, entry := range entries {
+			sizeCounts[entry.Size] += 1
+		}
+		for _, entry := range entries {
+			if sizeCounts[entry.Size] > 1 {
+				toHash[entry.Size] = append(toHash[entry.Size], entry)
+			}
+		}
+	}
+	hashes := map[string]FileHashEntry{}
+	for _, entries := range HashFiles(toHash, hashConfig) {
+		for _, entry := range entries {
+			hashes[entry.File.Path()] = entry
+		}
+	}
+
+	groups := []NameGroup{}
+	for name, entries := range nameMap {
+		group := NameGroup{Name: name}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Path() < entries[j].Path() })
+		// number the versions in the order they first appear
+		versions := map[string]int{}
+		for _, entry := range entries {
+			hashEntry, ok := hashes[entry.Path()]
+			if !ok {
+				hashEntry = FileHashEntry{File: entry}
+			}
+			key := strconv.FormatInt(entry.Size, 10) + ":" + hashEntry.Hash.String()
+			if _, ok := versions[key]; !ok {
+				versions[key] = len(versions) + 1
+			}
+			group.Entries = append(group.Entries, hashEntry)
+			group.Versions = append(group.Versions, versions[key])
+		}
+		if len(versions) > 1 {
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
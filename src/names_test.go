@@ -0,0 +1,53 @@
+package finder
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// test cases for finding files with the same name and different contents
+func TestFindSameNameFiles(t *testing.T) {
+	tempdir := t.TempDir()
+	dirs := []string{createSubDir(tempdir, "a"), createSubDir(tempdir, "b"), createSubDir(tempdir, "c")}
+	files := map[string]string{
+		"a/config.yml": "port: 80\n",
+		"b/config.yml": "port: 81\n", // same size, different contents
+		"c/config.yml": "port: 80\n",
+		"a/notes.md":   "foo\n",
+		"b/notes.md":   "foo bar\n", // different size
+		"a/same.txt":   "foo\n",
+		"b/same.txt":   "foo\n",
+		"c/unique.txt": "foo\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(tempdir, name), []byte(contents), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fileMap, _ := FindFilesSizes(tempdir, FindConfig{})
+	groups := FindSameNameFiles(fileMap, HashConfig{NumWorkers: 2})
+
+	got := ""
+	for _, group := range groups {
+		got += NameGroupFormatter(group, FormatConfig{Size: true})
+	}
+	want := "config.yml\tv1\t9\t" + filepath.Join(dirs[0], "config.yml") + "\n" +
+		"config.yml\tv2\t9\t" + filepath.Join(dirs[1], "config.yml") + "\n" +
+		"config.yml\tv1\t9\t" + filepath.Join(dirs[2], "config.yml") + "\n" +
+		"notes.md\tv1\t4\t" + filepath.Join(dirs[0], "notes.md") + "\n" +
+		"notes.md\tv2\t8\t" + filepath.Join(dirs[1], "notes.md") + "\n"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+	}
+
+	// only files whose size matched another copy get hashed
+	if groups[0].Entries[0].Hash.IsZero() || !groups[1].Entries[0].Hash.IsZero() {
+		t.Errorf("got entries %+v %+v", groups[0].Entries, groups[1].Entries)
+	}
+	if groups[0].NumVersions() != 2 {
+		t.Errorf("got %v versions", groups[0].NumVersions())
+	}
+}
@@ -1,27 +1,85 @@
 package finder
 
 import (
+	"encoding/hex"
 	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
+	"time"
 )
 
 // basic file entry
+// the path is kept as its dir and basename so that the files found in the same dir can share a single copy of the dir
 type FileEntry struct {
-	Path string
-	Name string // basename of the file
-	Size int64
+	Dir     string // dir part of the path including the trailing separator, empty if the path has no dir
+	Name    string // basename of the file
+	Size    int64
+	ModTime time.Time
+}
+
+// the full path of the file, the same as it was given or found
+func (entry FileEntry) Path() string {
+	return entry.Dir + entry.Name
+}
+
+// hash of a file stored as its raw bytes instead of a hex string, which takes half the space and only as much as
+// the algorithm makes, e.g. 8 bytes for xxhash and 16 for md5; can be used as a map key
+type HashSum struct {
+	sum string
+}
+
+// copy the output of hash.Hash.Sum into a HashSum
+func NewHashSum(sum []byte) HashSum {
+	return HashSum{sum: string(sum)}
+}
+
+// read a hash written as a hex string, e.g. by HashSum.String or sha256sum
+func ParseHashSum(hexStr string) (HashSum, error) {
+	sum, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return HashSum{}, err
+	}
+	return NewHashSum(sum), nil
+}
+
+// the hash as a lowercase hex string, the same as md5sum and sha256sum print it
+func (hashSum HashSum) String() string {
+	return hex.EncodeToString([]byte(hashSum.sum))
+}
+
+// number of bytes in the hash
+func (hashSum HashSum) Len() int {
+	return len(hashSum.sum)
+}
+
+// check if no hash was set, e.g. for a file that did not need to be hashed
+func (hashSum HashSum) IsZero() bool {
+	return hashSum.sum == ""
+}
+
+// check if two hashes are the same, the same as ==
+func (hashSum HashSum) Equal(other HashSum) bool {
+	return hashSum.sum == other.sum
+}
+
+// sort order of hashes, the same as for their hex strings
+func (hashSum HashSum) Less(other HashSum) bool {
+	return hashSum.sum < other.sum
 }
 
 // file entry with hash
 type FileHashEntry struct {
-	File FileEntry
-	Hash string
+	File         FileEntry
+	Hash         HashSum
+	Sample       string // describes the sampled parts of the file used for the hash; empty if the whole file was hashed
+	Normalized   bool   // the hash is of the normalized text of the file rather than its raw bytes
+	Decompressed string // compression format of the file if the hash is of its decompressed contents
 }
 
 // method for creating a new FileEntry when we have only the filepath available
-func NewFileEntryFromPath(filepath string) FileEntry {
-	file, err := os.Open(filepath)
+func NewFileEntryFromPath(path string) FileEntry {
+	file, err := os.Open(path)
 	if err != nil {
 		log.Fatalf("error opening the path %v\n", err)
 	}
@@ -32,26 +90,48 @@ func NewFileEntryFromPath(filepath string) FileEntry {
 		log.Fatal(err)
 	}
 
+	dir, name := filepath.Split(path)
 	entry := FileEntry{
-		Path: filepath,
-		Name: info.Name(),
-		Size: info.Size(),
+		Dir:     dir,
+		Name:    name,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
 	}
 
 	return entry
 }
 
 // use this to create FileEntry if file info has already been called
-func NewFileEntryFromPathInfo(filepath string, fileinfo fs.FileInfo) FileEntry {
+func NewFileEntryFromPathInfo(path string, fileinfo fs.FileInfo) FileEntry {
+	dir, name := filepath.Split(path)
 	entry := FileEntry{
-		Path: filepath,
-		Name: fileinfo.Name(),
-		Size: fileinfo.Size(),
+		Dir:     dir,
+		Name:    name,
+		Size:    fileinfo.Size(),
+		ModTime: fileinfo.ModTime(),
 	}
 
 	return entry
 }
 
+// keeps a single copy of each dir so that all the file entries in a dir share it
+// without this every entry would hold its own copy of the full path
+type dirInterner map[string]string
+
+// create a FileEntry that shares its dir with the other entries made by the interner
+func (dirs dirInterner) newFileEntry(path string, info fs.FileInfo) FileEntry {
+	entry := NewFileEntryFromPathInfo(path, info)
+	dir, ok := dirs[entry.Dir]
+	if !ok {
+		// copy the dir and name so that they do not keep the whole path in memory
+		dir = string([]byte(entry.Dir))
+		dirs[dir] = dir
+	}
+	entry.Dir = dir
+	entry.Name = string([]byte(entry.Name))
+	return entry
+}
+
 func NewFileHashEntry(fileEntry FileEntry, hashConfig HashConfig) FileHashEntry {
 	fileHashEntry, err := GetFileHash(fileEntry, hashConfig)
 	if err != nil {
@@ -0,0 +1,13 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package finder
+
+import (
+	"io/fs"
+)
+
+// file ownership is not available from the file info on this platform
+func fileOwner(info fs.FileInfo) string {
+	return ""
+}
@@ -0,0 +1,33 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package finder
+
+import (
+	"io/fs"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// cache user name lookups since the same few owners show up over and over
+var ownerNames sync.Map
+
+// get the name of the user that owns the file, or their uid if the name cannot be found
+func fileOwner(info fs.FileInfo) string {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	uid := strconv.FormatUint(uint64(stat.Uid), 10)
+	if name, ok := ownerNames.Load(uid); ok {
+		return name.(string)
+	}
+	name := uid
+	if owner, err := user.LookupId(uid); err == nil {
+		name = owner.Username
+	}
+	ownerNames.Store(uid, name)
+	return name
+}
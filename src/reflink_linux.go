@@ -0,0 +1,84 @@
+//go:build linux
+// +build linux
+
+package finder
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+)
+
+// https://man7.org/linux/man-pages/man2/ioctl_fideduperange.2.html
+const (
+	dedupeRangeSame    = 0
+	dedupeRangeDiffers = 1
+	// some filesystems limit how much can be deduplicated in one call so do it in chunks
+	dedupeChunkSize = 16 * 1024 * 1024
+)
+
+// make dst share the data extents of src using the FIDEDUPERANGE ioctl
+// unlike FICLONE the kernel locks both files and checks that their contents are identical
+// before sharing anything, so a file that changed since it was hashed is left alone
+func reflinkFile(src string, dst string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	// the destination needs to be opened for writing unless we own it or are root
+	dstFile, err := os.OpenFile(dst, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	info, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+	size := uint64(info.Size())
+
+	var offset uint64
+	for offset < size {
+		length := size - offset
+		if length > dedupeChunkSize {
+			length = dedupeChunkSize
+		}
+		dedupe := unix.FileDedupeRange{
+			Src_offset: offset,
+			Src_length: length,
+			Info:       []unix.FileDedupeRangeInfo{{Dest_fd: int64(dstFile.Fd()), Dest_offset: offset}},
+		}
+		err = unix.IoctlFileDedupeRange(int(srcFile.Fd()), &dedupe)
+		if err != nil {
+			return reflinkError(err)
+		}
+
+		result := dedupe.Info[0]
+		if result.Status == dedupeRangeDiffers {
+			return fmt.Errorf("contents of %v differ from %v at offset %v", dst, src, offset)
+		}
+		if result.Status < 0 {
+			return reflinkError(unix.Errno(-result.Status))
+		}
+		if result.Bytes_deduped == 0 {
+			return fmt.Errorf("no bytes were deduplicated at offset %v", offset)
+		}
+		offset += result.Bytes_deduped
+	}
+	return nil
+}
+
+// wrap the errors that mean the filesystem cannot share extents so that callers can check for them
+func reflinkError(err error) error {
+	if errors.Is(err, unix.EOPNOTSUPP) ||
+		errors.Is(err, unix.ENOTTY) ||
+		errors.Is(err, unix.EINVAL) ||
+		errors.Is(err, unix.EXDEV) {
+		return fmt.Errorf("%w: %v", ErrReflinkUnsupported, err)
+	}
+	return err
+}
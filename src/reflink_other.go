@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package finder
+
+// reflinks rely on Linux specific ioctls
+func reflinkFile(src string, dst string) error {
+	return ErrReflinkUnsupported
+}
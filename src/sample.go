@@ -0,0 +1,112 @@
+package finder
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// ways to pick which bytes of a file get hashed when HashConfig.Partial is set
+const (
+	SampleHead     = "head"      // first n bytes
+	SampleTail     = "tail"      // last n bytes
+	SampleHeadTail = "head-tail" // first n bytes and last n bytes
+	SampleSpread   = "spread"    // first n bytes, last n bytes, and NumBlocks evenly spaced n byte blocks in between
+)
+
+// default number of blocks to hash in between the head and tail for SampleSpread
+const DefaultSampleBlocks = 4
+
+// a section of a file that gets hashed
+type sampleRegion struct {
+	Offset int64
+	Length int64
+}
+
+// get the sampling mode and number of blocks to use, filling in defaults
+func (config HashConfig) sampleMode() (string, int, error) {
+	mode := config.Sample
+	if mode == "" {
+		mode = SampleHead
+	}
+	numBlocks := config.NumBlocks
+	if numBlocks <= 0 {
+		numBlocks = DefaultSampleBlocks
+	}
+	switch mode {
+	case SampleHead, SampleTail, SampleHeadTail, SampleSpread:
+		return mode, numBlocks, nil
+	default:
+		return "", 0, fmt.Errorf("unknown sample mode %q, valid options are: %s, %s, %s, %s", mode, SampleHead, SampleTail, SampleHeadTail, SampleSpread)
+	}
+}
+
+// get the regions of a file of the given size that should be hashed
+// returns nil if the whole file should be hashed instead, either because partial hashing is disabled
+// or because the file is small enough that the sample would cover all of it anyway
+func (config HashConfig) sampleRegions(size int64) ([]sampleRegion, error) {
+	if !config.Partial || config.NumBytes <= 0 {
+		return nil, nil
+	}
+	mode, numBlocks, err := config.sampleMode()
+	if err != nil {
+		return nil, err
+	}
+
+	n := config.NumBytes
+	var numRegions int64
+	switch mode {
+	case SampleHead, SampleTail:
+		numRegions = 1
+	case SampleHeadTail:
+		numRegions = 2
+	case SampleSpread:
+		numRegions = int64(numBlocks) + 2
+	}
+	if size <= n*numRegions {
+		return nil, nil
+	}
+
+	regions := []sampleRegion{}
+	switch mode {
+	case SampleHead:
+		regions = append(regions, sampleRegion{Offset: 0, Length: n})
+	case SampleTail:
+		regions = append(regions, sampleRegion{Offset: size - n, Length: n})
+	case SampleHeadTail:
+		regions = append(regions, sampleRegion{Offset: 0, Length: n}, sampleRegion{Offset: size - n, Length: n})
+	case SampleSpread:
+		// evenly space all the blocks from the start of the file to the start of the tail block
+		last := size - n
+		for i := int64(0); i < numRegions; i++ {
+			offset := i * last / (numRegions - 1)
+			regions = append(regions, sampleRegion{Offset: offset, Length: n})
+		}
+	}
+	return regions, nil
+}
+
+// label describing how a file was sampled, for printing in the output
+func (config HashConfig) sampleLabel() string {
+	mode, numBlocks, err := config.sampleMode()
+	if err != nil {
+		return ""
+	}
+	if mode == SampleSpread {
+		return fmt.Sprintf("sampled:%s:%dx%d", mode, numBlocks+2, config.NumBytes)
+	}
+	return fmt.Sprintf("sampled:%s:%d", mode, config.NumBytes)
+}
+
+// hash only the sampled regions of the file
+func hashRegions(hashWriter hash.Hash, inputFile *os.File, regions []sampleRegion) error {
+	for _, region := range regions {
+		section := io.NewSectionReader(inputFile, region.Offset, region.Length)
+		_, err := io.Copy(hashWriter, section)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
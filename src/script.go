@@ -0,0 +1,129 @@
+package finder
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// shell commands that can be written to the script for each redundant copy
+const (
+	ScriptRemove   = "rm"   // delete the copy
+	ScriptHardlink = "ln"   // replace the copy with a hardlink to the kept file
+	ScriptSymlink  = "ln-s" // replace the copy with a symlink to the kept file
+	ScriptMove     = "mv"   // move the copy into another dir, keeping its path relative to Root
+)
+
+type ScriptConfig struct {
+	Action           string // one of ScriptRemove (default), ScriptHardlink, ScriptSymlink, ScriptMove
+	Active           bool   // write the commands without the leading comment so that the script runs as is; never applies to groups that were not matched by a hash of the whole file
+	RelativeSymlinks bool   // use relative paths for the targets of ScriptSymlink
+	Root             string // dir that was searched, for ScriptMove
+	MoveDir          string // dir to move the copies into, for ScriptMove
+}
+
+// quote a string for use as a single word in a POSIX shell
+// newlines are written as a reference to the $nl variable defined in the script header
+// so that every command stays on one line and can be commented out safely
+func shellQuote(s string) string {
+	s = strings.ReplaceAll(s, "'", `'\''`)
+	s = strings.ReplaceAll(s, "\n", `'"$nl"'`)
+	return "'" + s + "'"
+}
+
+// get the shell command that handles a single redundant copy
+func scriptCommand(keep string, path string, config ScriptConfig) (string, error) {
+	switch config.Action {
+	case ScriptRemove, "":
+		return "rm -f -- " + shellQuote(path), nil
+	case ScriptHardlink:
+		return "ln -f -- " + shellQuote(keep) + " " + shellQuote(path), nil
+	case ScriptSymlink:
+		target, err := symlinkTarget(keep, path, config.RelativeSymlinks)
+		if err != nil {
+			return "", err
+		}
+		return "ln -sf -- " + shellQuote(target) + " " + shellQuote(path), nil
+	case ScriptMove:
+		dest, err := relocatePath(path, config.Root, config.MoveDir)
+		if err != nil {
+			return "", err
+		}
+		return "mkdir -p -- " + shellQuote(filepath.Dir(dest)) + " && mv -n -- " + shellQuote(path) + " " + shellQuote(dest), nil
+	default:
+		return "", fmt.Errorf("unknown script action %q, valid options are: %s, %s, %s, %s", config.Action, ScriptRemove, ScriptHardlink, ScriptSymlink, ScriptMove)
+	}
+}
+
+// describe how the hashes of a group were made if any of them are not a plain hash of the whole file
+// the copies in such a group may have different contents, so they need to be checked before running anything
+func inexactGroupNotes(entries []FileHashEntry) string {
+	for _, entry := range entries {
+		if notes := hashEntryNotes(entry); notes != "" {
+			return strings.ReplaceAll(strings.TrimPrefix(notes, "\t"), "\t", " ")
+		}
+	}
+	return ""
+}
+
+// write a POSIX shell script with a command for every redundant copy of each duplicate group
+// so that the changes can be reviewed and edited before anything is run
+func WriteScript(writer io.Writer, dupes map[HashSum][]FileHashEntry, config ScriptConfig) error {
+	var prefix string
+	if !config.Active {
+		prefix = "#"
+	}
+
+	header := "#!/bin/sh\n" +
+		"# duplicate files found by dupefinder\n" +
+		"# review this script before running it!\n"
+	if !config.Active {
+		header += "# commands are commented out; remove the leading '#' from the ones you want to run\n"
+	}
+	header += "set -eu\n" +
+		"# used to quote file names that contain newlines\n" +
+		"nl='\n'\n"
+	_, err := io.WriteString(writer, header)
+	if err != nil {
+		return err
+	}
+
+	// same safeguard as LinkDupes
+	var unsafeDirs map[string]bool
+	if config.Action == ScriptSymlink {
+		unsafeDirs = modifiedDirs(dupes)
+	}
+
+	for _, hash := range sortedDupeHashes(dupes) {
+		keep, redundant := SplitKeeper(dupes[hash])
+		lines := []string{
+			"",
+			"# hash " + hash.String() + " copies " + strconv.Itoa(len(redundant)+1),
+		}
+		groupPrefix := prefix
+		if notes := inexactGroupNotes(dupes[hash]); notes != "" {
+			lines = append(lines, "# hash is not of the whole file ("+notes+") so the copies may differ; check them before running these commands")
+			groupPrefix = "#"
+		}
+		lines = append(lines, "# keep "+shellQuote(keep.File.Path())+" size "+strconv.FormatInt(keep.File.Size, 10))
+		keptDir, _ := filepath.Abs(filepath.Dir(keep.File.Path()))
+		for _, entry := range redundant {
+			if unsafeSymlink(unsafeDirs, keptDir, entry.File.Path()) {
+				lines = append(lines, "# skipped "+shellQuote(entry.File.Path())+" because files in the kept dir are also being replaced")
+				continue
+			}
+			command, err := scriptCommand(keep.File.Path(), entry.File.Path(), config)
+			if err != nil {
+				return err
+			}
+			lines = append(lines, groupPrefix+command+" # size "+strconv.FormatInt(entry.File.Size, 10))
+		}
+		_, err = io.WriteString(writer, strings.Join(lines, "\n")+"\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
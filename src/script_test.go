@@ -0,0 +1,111 @@
+package finder
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// test for writing a shell script of the actions to take on duplicates
+func TestWriteScript(t *testing.T) {
+	shell, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell available to run the script")
+	}
+
+	// awkward file names need to survive quoting
+	names := []string{"a", "it's here", "new\nline", "-dash", "$(touch pwned)"}
+	setup := func(t *testing.T) string {
+		tempdir := t.TempDir()
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(tempdir, name), []byte("foo\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return tempdir
+	}
+
+	tests := map[string]struct {
+		config     ScriptConfig
+		hashConfig HashConfig
+		wantFiles  int // number of regular files left in the dir after running the script
+	}{
+		"commented_out": {
+			config:    ScriptConfig{Action: ScriptRemove},
+			wantFiles: len(names),
+		},
+		"remove": {
+			config:    ScriptConfig{Action: ScriptRemove, Active: true},
+			wantFiles: 1,
+		},
+		"hardlink": {
+			config:    ScriptConfig{Action: ScriptHardlink, Active: true},
+			wantFiles: len(names),
+		},
+		"symlink": {
+			config:    ScriptConfig{Action: ScriptSymlink, Active: true, RelativeSymlinks: true},
+			wantFiles: 1,
+		},
+		"move": {
+			config:    ScriptConfig{Action: ScriptMove, Active: true, MoveDir: "moved"},
+			wantFiles: 1,
+		},
+		// only part of each file was hashed, so the commands stay commented out
+		"sampled": {
+			config:     ScriptConfig{Action: ScriptRemove, Active: true},
+			hashConfig: HashConfig{NumBytes: 2, Partial: true},
+			wantFiles:  len(names),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tempdir := setup(t)
+			dupes, _ := FindDupes(tempdir, FindConfig{}, tc.hashConfig)
+			if tc.config.Action == ScriptMove {
+				tc.config.Root = tempdir
+				tc.config.MoveDir = filepath.Join(tempdir, tc.config.MoveDir)
+			}
+			script := new(bytes.Buffer)
+			err := WriteScript(script, dupes, tc.config)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+
+			scriptStr := script.String()
+			cmd := exec.Command(shell, "-s")
+			cmd.Dir = tempdir
+			cmd.Stdin = script
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("script failed: %v %s\n%s", err, output, scriptStr)
+			}
+
+			if _, err := os.Stat(filepath.Join(tempdir, "pwned")); err == nil {
+				t.Errorf("file name was not quoted properly:\n%s", scriptStr)
+			}
+			entries, _ := os.ReadDir(tempdir)
+			var numFiles int
+			for _, entry := range entries {
+				if entry.Type().IsRegular() {
+					numFiles += 1
+				}
+			}
+			if numFiles != tc.wantFiles {
+				t.Errorf("got %v files, wanted %v:\n%s", numFiles, tc.wantFiles, scriptStr)
+			}
+			// the kept file is always left alone
+			if _, err := os.Stat(filepath.Join(tempdir, "$(touch pwned)")); err != nil {
+				t.Errorf("kept file is missing: %v", err)
+			}
+			if tc.hashConfig.NumBytes == 0 && !strings.Contains(scriptStr, "# hash d3b07384d113edec49eaa6238ad5ff00 copies 5") {
+				t.Errorf("missing group comment:\n%s", scriptStr)
+			}
+			if !strings.Contains(scriptStr, "# keep '"+filepath.Join(tempdir, "$(touch pwned)")+"' size 4") {
+				t.Errorf("missing kept file comment:\n%s", scriptStr)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package finder
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// a set of duplicates in the JSON output
+type dupeGroup struct {
+	Hash  string     `json:"hash"`
+	Files []dupeFile `json:"files"`
+}
+
+type dupeFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// serve the duplicates in a dir, as text at / in the same format as DupesFormatter and as JSON at /dupes
+// the dir is searched again for each request so that the results are never stale;
+// only one search runs at a time so that a burst of requests does not hash the same files over and over in parallel;
+// if the dir cannot be searched, e.g. since it was removed, the request fails instead of stopping the server
+func DupesHandler(dirPath string, findConfig FindConfig, hashConfig HashConfig, formatConfig FormatConfig) http.Handler {
+	var mutex sync.Mutex
+	searchDupes := func(writer http.ResponseWriter) (map[HashSum][]FileHashEntry, bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		dupes, _, err := findDupes(dirPath, findConfig, hashConfig)
+		if err != nil {
+			log.Printf("WARNING: Could not search %v: %v\n", dirPath, err)
+			http.Error(writer, fmt.Sprintf("could not search %v: %v", dirPath, err), http.StatusInternalServerError)
+			return nil, false
+		}
+		return dupes, true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/" {
+			http.NotFound(writer, request)
+			return
+		}
+		dupes, ok := searchDupes(writer)
+		if !ok {
+			return
+		}
+		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, hash := range sortedDupeHashes(dupes) {
+			fmt.Fprintf(writer, "%s", DupesFormatter(dupes[hash], formatConfig))
+		}
+	})
+	mux.HandleFunc("/dupes", func(writer http.ResponseWriter, request *http.Request) {
+		dupes, ok := searchDupes(writer)
+		if !ok {
+			return
+		}
+		groups := []dupeGroup{}
+		for _, hash := range sortedDupeHashes(dupes) {
+			group := dupeGroup{Hash: hash.String()}
+			for _, entry := range dupes[hash] {
+				group.Files = append(group.Files, dupeFile{Path: entry.File.Path(), Size: entry.File.Size, ModTime: entry.File.ModTime})
+			}
+			groups = append(groups, group)
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(writer).Encode(groups); err != nil {
+			log.Printf("WARNING: Could not write response: %v\n", err)
+		}
+	})
+	return mux
+}
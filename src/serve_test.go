@@ -0,0 +1,97 @@
+package finder
+
+import (
+	"encoding/json"
+	"github.com/google/go-cmp/cmp"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// get the body of a response from the handler
+func serveRequest(t *testing.T, handler http.Handler, path string) (int, string) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+	body, err := io.ReadAll(recorder.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Code, string(body)
+}
+
+// test cases for serving the duplicates over HTTP
+func TestDupesHandler(t *testing.T) {
+	tempdir := t.TempDir()
+	files := map[string]string{
+		"a.txt": "foo\n",
+		"b.txt": "foo\n",
+		"c.txt": "bar\n", // same size, different contents
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(tempdir, name), []byte(contents), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	handler := DupesHandler(tempdir, FindConfig{}, HashConfig{NumWorkers: 1}, FormatConfig{})
+	wantHash := "d3b07384d113edec49eaa6238ad5ff00"
+
+	t.Run("Test text output", func(t *testing.T) {
+		code, got := serveRequest(t, handler, "/")
+		want := wantHash + "\t" + filepath.Join(tempdir, "a.txt") + "\n" +
+			wantHash + "\t" + filepath.Join(tempdir, "b.txt") + "\n"
+		if code != http.StatusOK {
+			t.Errorf("got status %v", code)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Test JSON output", func(t *testing.T) {
+		code, body := serveRequest(t, handler, "/dupes")
+		if code != http.StatusOK {
+			t.Errorf("got status %v", code)
+		}
+		got := []dupeGroup{}
+		if err := json.Unmarshal([]byte(body), &got); err != nil {
+			t.Fatalf("could not parse %q: %v", body, err)
+		}
+		if len(got) != 1 || got[0].Hash != wantHash || len(got[0].Files) != 2 {
+			t.Fatalf("got %+v", got)
+		}
+		for i, name := range []string{"a.txt", "b.txt"} {
+			if got[0].Files[i].Path != filepath.Join(tempdir, name) || got[0].Files[i].Size != 4 {
+				t.Errorf("got file %+v for %v", got[0].Files[i], name)
+			}
+		}
+	})
+
+	t.Run("Test the dir is searched again for each request", func(t *testing.T) {
+		if err := os.Remove(filepath.Join(tempdir, "b.txt")); err != nil {
+			log.Fatal(err)
+		}
+		_, got := serveRequest(t, handler, "/dupes")
+		if diff := cmp.Diff("[]\n", got); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Test unknown paths", func(t *testing.T) {
+		if code, _ := serveRequest(t, handler, "/other"); code != http.StatusNotFound {
+			t.Errorf("got status %v", code)
+		}
+	})
+	t.Run("Test a dir that cannot be searched", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "missing")
+		handler := DupesHandler(missing, FindConfig{}, HashConfig{NumWorkers: 1}, FormatConfig{})
+		for _, path := range []string{"/", "/dupes"} {
+			if code, _ := serveRequest(t, handler, path); code != http.StatusInternalServerError {
+				t.Errorf("got status %v for %v", code, path)
+			}
+		}
+	})
+}
@@ -0,0 +1,243 @@
+package finder
+
+import (
+	"github.com/cespare/xxhash"
+	"io"
+	"math/bits"
+	"os"
+	"sort"
+	"sync"
+)
+
+// default average size of the content defined chunks; smaller chunks find more overlap but use more memory
+const DefaultChunkSize = 8192
+
+// default percentage of the larger file that two files need to share to be reported as similar
+const DefaultSimilarPercent = 50.0
+
+// default max number of files that a chunk can be in for it to be counted by FindSimilarFiles
+// chunks like a block of zeros or a common header are in a lot of files and would need to be counted for every pair of them
+const DefaultMaxChunkFiles = 100
+
+// number of bytes the rolling hash looks at when deciding where chunks end
+const chunkWindow = 48
+
+// random values for each byte used by the buzhash rolling hash
+// generated with splitmix64 from a fixed seed so that chunk boundaries are the same on every run
+var buzhashTable = func() [256]uint32 {
+	var table [256]uint32
+	state := uint64(0x6475706566696e64)
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = uint32(z ^ (z >> 31))
+	}
+	return table
+}()
+
+type SimilarConfig struct {
+	MinPercent    float64 // report pairs of files that share at least this percentage of the bytes of the larger file
+	ChunkSize     int     // average chunk size in bytes, rounded down to a power of 2; defaults to DefaultChunkSize
+	MaxChunkFiles int     // chunks that are in more files than this are not counted as shared; defaults to DefaultMaxChunkFiles
+	NumWorkers    int
+	Verbose       bool
+}
+
+// pair of files that share some of their contents
+type SimilarPair struct {
+	A           FileEntry
+	B           FileEntry
+	SharedBytes int64   // estimated number of bytes of the larger file that are also in the other file
+	Percent     float64 // SharedBytes as a percentage of the size of the larger file
+}
+
+// the distinct chunks of a file and how many bytes of the file each one covers
+type fileChunks struct {
+	File   FileEntry
+	Chunks map[uint64]int64
+}
+
+type fileChunksResult struct {
+	Chunks fileChunks
+	Err    error
+}
+
+// split a stream into content defined chunks and get the hash and size of each one
+// chunk boundaries depend only on the bytes just before them, so inserting or removing data
+// only changes the chunks around the edit instead of shifting every chunk after it
+func chunkReader(reader io.Reader, chunkSize int) (map[uint64]int64, error) {
+	if chunkSize < chunkWindow {
+		chunkSize = DefaultChunkSize
+	}
+	// boundaries are where the low bits of the rolling hash are all zero
+	mask := uint32(1)<<uint(bits.Len(uint(chunkSize))-1) - 1
+	minSize := chunkSize / 4
+	maxSize := chunkSize * 8
+
+	chunks := map[uint64]int64{}
+	var window [chunkWindow]byte
+	var windowPos int
+	var windowFull bool
+	var rolling uint32
+	chunk := make([]byte, 0, maxSize)
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, err := reader.Read(buf)
+		for _, b := range buf[:n] {
+			rolling = bits.RotateLeft32(rolling, 1) ^ buzhashTable[b]
+			// drop the byte leaving the window, which has been rotated once for every byte since it was added
+			if windowFull {
+				rolling ^= bits.RotateLeft32(buzhashTable[window[windowPos]], chunkWindow%32)
+			}
+			window[windowPos] = b
+			windowPos = (windowPos + 1) % chunkWindow
+			windowFull = windowFull || windowPos == 0
+
+			chunk = append(chunk, b)
+			if (len(chunk) >= minSize && rolling&mask == 0) || len(chunk) >= maxSize {
+				chunks[xxhash.Sum64(chunk)] += int64(len(chunk))
+				chunk = chunk[:0]
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return chunks, err
+		}
+	}
+	if len(chunk) > 0 {
+		chunks[xxhash.Sum64(chunk)] += int64(len(chunk))
+	}
+	return chunks, nil
+}
+
+// split a file into content defined chunks
+func getFileChunks(fileEntry FileEntry, chunkSize int) (fileChunks, error) {
+	file, err := os.Open(fileEntry.Path())
+	if err != nil {
+		return fileChunks{}, err
+	}
+	defer file.Close()
+	chunks, err := chunkReader(file, chunkSize)
+	if err != nil {
+		return fileChunks{}, err
+	}
+	return fileChunks{File: fileEntry, Chunks: chunks}, nil
+}
+
+// find pairs of files that share a large part of their contents, such as VM images, database dumps,
+// and logs that are mostly the same, by splitting every file into content defined chunks and
+// comparing the chunks that each pair of files has in common
+// files that cannot be read are skipped with a warning
+func FindSimilarFiles(fileMap map[int64][]FileEntry, config SimilarConfig) []SimilarPair {
+	numWorkers := config.NumWorkers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	maxChunkFiles := config.MaxChunkFiles
+	if maxChunkFiles < 1 {
+		maxChunkFiles = DefaultMaxChunkFiles
+	}
+
+	work := make(chan FileEntry)
+	results := make(chan fileChunksResult)
+	wg := sync.WaitGroup{}
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fileEntry := range work {
+				if config.Verbose {
+					logger.Printf("Chunking %v\n", fileEntry.Path())
+				}
+				chunks, err := getFileChunks(fileEntry, config.ChunkSize)
+				results <- fileChunksResult{Chunks: chunks, Err: err}
+			}
+		}()
+	}
+
+	go func() {
+		for _, entries := range fileMap {
+			for _, entry := range entries {
+				// empty files have nothing to share
+				if entry.Size > 0 {
+					work <- entry
+				}
+			}
+		}
+		close(work)
+		wg.Wait()
+		close(results)
+	}()
+
+	files := []fileChunks{}
+	for result := range results {
+		if result.Err != nil {
+			logger.Printf("WARNING: Skipping file that could not be read: %v\n", result.Err)
+			continue
+		}
+		files = append(files, result.Chunks)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].File.Path() < files[j].File.Path() })
+
+	// index which files hold each chunk, then add up the shared bytes for every pair of files that have a chunk in common
+	index := map[uint64][]int{}
+	for i, file := range files {
+		for chunk := range file.Chunks {
+			index[chunk] = append(index[chunk], i)
+		}
+	}
+	type filePair struct{ a, b int }
+	shared := map[filePair]int64{}
+	var numCommonChunks int
+	for chunk, holders := range index {
+		// the number of pairs grows with the square of the number of files, so skip the chunks that are in too many
+		if len(holders) > maxChunkFiles {
+			numCommonChunks += 1
+			continue
+		}
+		for x := 0; x < len(holders); x++ {
+			for y := x + 1; y < len(holders); y++ {
+				a, b := holders[x], holders[y]
+				// count the bytes of the chunk in the larger file, since that is what the percentage is of
+				sharedBytes := files[a].Chunks[chunk]
+				if files[b].File.Size > files[a].File.Size {
+					sharedBytes = files[b].Chunks[chunk]
+				}
+				shared[filePair{a, b}] += sharedBytes
+			}
+		}
+	}
+
+	pairs := []SimilarPair{}
+	for pair, sharedBytes := range shared {
+		a, b := files[pair.a].File, files[pair.b].File
+		largest := a.Size
+		if b.Size > largest {
+			largest = b.Size
+		}
+		percent := 100 * float64(sharedBytes) / float64(largest)
+		if percent >= config.MinPercent {
+			pairs = append(pairs, SimilarPair{A: a, B: b, SharedBytes: sharedBytes, Percent: percent})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Percent != pairs[j].Percent {
+			return pairs[i].Percent > pairs[j].Percent
+		}
+		if pairs[i].A.Path() != pairs[j].A.Path() {
+			return pairs[i].A.Path() < pairs[j].A.Path()
+		}
+		return pairs[i].B.Path() < pairs[j].B.Path()
+	})
+
+	if config.Verbose {
+		logger.Printf("Skipped %v chunks that were in more than %v files\n", numCommonChunks, maxChunkFiles)
+		logger.Printf("Chunked %v files and found %v similar pairs\n", len(files), len(pairs))
+	}
+	return pairs
+}
@@ -0,0 +1,95 @@
+package finder
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// test cases for finding files that share most of their contents
+func TestFindSimilarFiles(t *testing.T) {
+	tempdir := t.TempDir()
+	random := rand.New(rand.NewSource(1))
+	original := make([]byte, 200000)
+	random.Read(original)
+	other := make([]byte, 200000)
+	random.Read(other)
+
+	// insert some bytes in the middle so that every fixed size block after it would be shifted
+	edited := append([]byte{}, original[:100000]...)
+	edited = append(edited, bytes.Repeat([]byte("edit"), 25)...)
+	edited = append(edited, original[100000:]...)
+
+	files := map[string][]byte{
+		"original.img": original,
+		"edited.img":   edited,
+		"other.img":    other,
+		"empty.img":    {},
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(tempdir, name), contents, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fileMap, _ := FindFilesSizes(tempdir, FindConfig{})
+
+	t.Run("Find the edited copy", func(t *testing.T) {
+		pairs := FindSimilarFiles(fileMap, SimilarConfig{MinPercent: DefaultSimilarPercent, NumWorkers: 2})
+		if len(pairs) != 1 {
+			t.Fatalf("got %v pairs, want 1: %+v", len(pairs), pairs)
+		}
+		pair := pairs[0]
+		if filepath.Base(pair.A.Path()) != "edited.img" || filepath.Base(pair.B.Path()) != "original.img" {
+			t.Errorf("got pair %v %v", pair.A.Path(), pair.B.Path())
+		}
+		if pair.Percent < 80 || pair.Percent >= 100 {
+			t.Errorf("got percent %v", pair.Percent)
+		}
+		if pair.SharedBytes > int64(len(edited)) {
+			t.Errorf("got more shared bytes %v than the file size %v", pair.SharedBytes, len(edited))
+		}
+	})
+
+	t.Run("Skip chunks that are in too many files", func(t *testing.T) {
+		commondir := t.TempDir()
+		header := original[:50000]
+		for i := 0; i < 4; i++ {
+			contents := append(append([]byte{}, header...), other[i*1000:(i+1)*1000]...)
+			if err := os.WriteFile(filepath.Join(commondir, fmt.Sprintf("%v.img", i)), contents, 0644); err != nil {
+				log.Fatal(err)
+			}
+		}
+		commonMap, _ := FindFilesSizes(commondir, FindConfig{})
+		// every pair shares the header
+		pairs := FindSimilarFiles(commonMap, SimilarConfig{MinPercent: DefaultSimilarPercent})
+		if len(pairs) != 6 {
+			t.Errorf("got %v pairs, want 6: %+v", len(pairs), pairs)
+		}
+		pairs = FindSimilarFiles(commonMap, SimilarConfig{MinPercent: DefaultSimilarPercent, MaxChunkFiles: 3})
+		if len(pairs) != 0 {
+			t.Errorf("got %v pairs, want 0: %+v", len(pairs), pairs)
+		}
+	})
+
+	t.Run("Chunks are the same on every run", func(t *testing.T) {
+		chunks1, _ := chunkReader(bytes.NewReader(original), DefaultChunkSize)
+		chunks2, _ := chunkReader(bytes.NewReader(original), DefaultChunkSize)
+		if len(chunks1) < 10 || len(chunks1) != len(chunks2) {
+			t.Errorf("got %v and %v chunks", len(chunks1), len(chunks2))
+		}
+		var total int64
+		for chunk, size := range chunks1 {
+			total += size
+			if chunks2[chunk] != size {
+				t.Errorf("chunk %v is not in both runs", chunk)
+			}
+		}
+		if total != int64(len(original)) {
+			t.Errorf("chunks cover %v bytes, want %v", total, len(original))
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package finder
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// how much duplicated data is in a single dir, including the dirs below it if they were collapsed into it
+type DirSpace struct {
+	Dir              string
+	DuplicatedBytes  int64 // bytes in files that have a copy somewhere else
+	ReclaimableBytes int64 // bytes that would be freed by removing the redundant copies picked by SplitKeeper
+	NumFiles         int   // number of files that have a copy somewhere else
+}
+
+// how much duplicated data two dirs have in common
+type DirPairSpace struct {
+	DirA        string
+	DirB        string
+	SharedBytes int64 // size of the contents that have a copy in both dirs, counted once
+	NumShared   int   // number of duplicate groups with a copy in both dirs
+}
+
+// duplicated space broken down by dir, largest first
+type SpaceReport struct {
+	Dirs             []DirSpace
+	Pairs            []DirPairSpace
+	ReclaimableBytes int64 // total for all the dirs
+}
+
+type SpaceConfig struct {
+	Root  string // dir that was searched; dirs are collapsed to Depth levels below it
+	Depth int    // number of dir levels below Root to report; 0 = report every dir separately
+}
+
+// get the dir that a file gets counted in, collapsing deeper dirs into their ancestor Depth levels below Root
+func (config SpaceConfig) spaceDir(path string) string {
+	dir := filepath.Dir(path)
+	if config.Depth <= 0 {
+		return dir
+	}
+	root := config.Root
+	// paths from a file list can be absolute even when the root is not
+	if filepath.IsAbs(dir) != filepath.IsAbs(root) {
+		dir, _ = filepath.Abs(dir)
+		root, _ = filepath.Abs(root)
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return dir
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if rel == "." || len(parts) <= config.Depth {
+		return dir
+	}
+	return filepath.Join(append([]string{root}, parts[:config.Depth]...)...)
+}
+
+// break down the space used by duplicates by the dir they are in, and by pairs of dirs that share copies,
+// to show where to start cleaning up
+func SpaceBreakdown(dupes map[HashSum][]FileHashEntry, config SpaceConfig) SpaceReport {
+	report := SpaceReport{}
+	dirs := map[string]*DirSpace{}
+	pairs := map[[2]string]*DirPairSpace{}
+
+	getDir := func(dir string) *DirSpace {
+		if _, ok := dirs[dir]; !ok {
+			dirs[dir] = &DirSpace{Dir: dir}
+		}
+		return dirs[dir]
+	}
+
+	for _, hash := range sortedDupeHashes(dupes) {
+		keep, redundant := SplitKeeper(dupes[hash])
+		size := keep.File.Size
+
+		keptDir := getDir(config.spaceDir(keep.File.Path()))
+		keptDir.DuplicatedBytes += size
+		keptDir.NumFiles += 1
+		groupDirs := map[string]bool{keptDir.Dir: true}
+		for _, entry := range redundant {
+			dir := getDir(config.spaceDir(entry.File.Path()))
+			dir.DuplicatedBytes += size
+			dir.ReclaimableBytes += size
+			dir.NumFiles += 1
+			report.ReclaimableBytes += size
+			groupDirs[dir.Dir] = true
+		}
+
+		sortedDirs := []string{}
+		for dir := range groupDirs {
+			sortedDirs = append(sortedDirs, dir)
+		}
+		sort.Strings(sortedDirs)
+		for i := 0; i < len(sortedDirs); i++ {
+			for j := i + 1; j < len(sortedDirs); j++ {
+				key := [2]string{sortedDirs[i], sortedDirs[j]}
+				if _, ok := pairs[key]; !ok {
+					pairs[key] = &DirPairSpace{DirA: key[0], DirB: key[1]}
+				}
+				pairs[key].SharedBytes += size
+				pairs[key].NumShared += 1
+			}
+		}
+	}
+
+	for _, dir := range dirs {
+		report.Dirs = append(report.Dirs, *dir)
+	}
+	sort.Slice(report.Dirs, func(i, j int) bool {
+		a, b := report.Dirs[i], report.Dirs[j]
+		if a.ReclaimableBytes != b.ReclaimableBytes {
+			return a.ReclaimableBytes > b.ReclaimableBytes
+		}
+		if a.DuplicatedBytes != b.DuplicatedBytes {
+			return a.DuplicatedBytes > b.DuplicatedBytes
+		}
+		return a.Dir < b.Dir
+	})
+	for _, pair := range pairs {
+		report.Pairs = append(report.Pairs, *pair)
+	}
+	sort.Slice(report.Pairs, func(i, j int) bool {
+		a, b := report.Pairs[i], report.Pairs[j]
+		if a.SharedBytes != b.SharedBytes {
+			return a.SharedBytes > b.SharedBytes
+		}
+		if a.DirA != b.DirA {
+			return a.DirA < b.DirA
+		}
+		return a.DirB < b.DirB
+	})
+	return report
+}
+
+// format a number of bytes the same way as 'du -h', so that the output can be sorted with 'sort -h'
+func HumanSize(size int64) string {
+	const units = "KMGTPE"
+	if size < 1024 {
+		return strconv.FormatInt(size, 10) + "B"
+	}
+	value := float64(size)
+	unit := -1
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit += 1
+	}
+	if value < 10 {
+		return strconv.FormatFloat(value, 'f', 1, 64) + string(units[unit])
+	}
+	return strconv.FormatFloat(value, 'f', 0, 64) + string(units[unit])
+}
@@ -0,0 +1,70 @@
+package finder
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"path/filepath"
+	"testing"
+)
+
+// test cases for breaking down duplicated space by dir
+func TestSpaceBreakdown(t *testing.T) {
+	newEntry := func(path string, size int64, hash HashSum) FileHashEntry {
+		dir, name := filepath.Split(path)
+		return FileHashEntry{File: FileEntry{Dir: dir, Name: name, Size: size}, Hash: hash}
+	}
+	dupes := map[HashSum][]FileHashEntry{
+		hashSum("aaaa"): {
+			newEntry("root/photos/2020/a.jpg", 1000, hashSum("aaaa")),
+			newEntry("root/backup/photos/2020/a.jpg", 1000, hashSum("aaaa")),
+			newEntry("root/backup/old/a.jpg", 1000, hashSum("aaaa")),
+		},
+		hashSum("bbbb"): {
+			newEntry("root/photos/2021/b.jpg", 500, hashSum("bbbb")),
+			newEntry("root/backup/photos/2021/b.jpg", 500, hashSum("bbbb")),
+		},
+	}
+
+	t.Run("Every dir", func(t *testing.T) {
+		report := SpaceBreakdown(dupes, SpaceConfig{Root: "root"})
+		if report.ReclaimableBytes != 2500 {
+			t.Errorf("got %v reclaimable bytes", report.ReclaimableBytes)
+		}
+		// the lexically first copy is kept, so only the copies in backup/old and backup/photos/2021 are not reclaimable
+		want := []DirSpace{
+			{Dir: "root/backup/photos/2020", DuplicatedBytes: 1000, ReclaimableBytes: 1000, NumFiles: 1},
+			{Dir: "root/photos/2020", DuplicatedBytes: 1000, ReclaimableBytes: 1000, NumFiles: 1},
+			{Dir: "root/photos/2021", DuplicatedBytes: 500, ReclaimableBytes: 500, NumFiles: 1},
+			{Dir: "root/backup/old", DuplicatedBytes: 1000, ReclaimableBytes: 0, NumFiles: 1},
+			{Dir: "root/backup/photos/2021", DuplicatedBytes: 500, ReclaimableBytes: 0, NumFiles: 1},
+		}
+		if diff := cmp.Diff(want, report.Dirs); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+		// 3 pairs for the dirs with a.jpg and 1 pair for the dirs with b.jpg
+		if len(report.Pairs) != 4 || report.Pairs[0].SharedBytes != 1000 {
+			t.Errorf("got pairs %+v", report.Pairs)
+		}
+	})
+
+	t.Run("Collapsed to the top level dirs", func(t *testing.T) {
+		report := SpaceBreakdown(dupes, SpaceConfig{Root: "root", Depth: 1})
+		want := []DirSpace{
+			{Dir: "root/photos", DuplicatedBytes: 1500, ReclaimableBytes: 1500, NumFiles: 2},
+			{Dir: "root/backup", DuplicatedBytes: 2500, ReclaimableBytes: 1000, NumFiles: 3},
+		}
+		if diff := cmp.Diff(want, report.Dirs); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+		wantPairs := []DirPairSpace{{DirA: "root/backup", DirB: "root/photos", SharedBytes: 1500, NumShared: 2}}
+		if diff := cmp.Diff(wantPairs, report.Pairs); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Human readable sizes", func(t *testing.T) {
+		got := []string{HumanSize(0), HumanSize(1023), HumanSize(1536), HumanSize(20 * 1024 * 1024), HumanSize(3 << 40)}
+		if diff := cmp.Diff([]string{"0B", "1023B", "1.5K", "20M", "3.0T"}, got); diff != "" {
+			t.Errorf("got vs want mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package finder
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// number of bytes at the start of a file that are checked for NUL bytes to decide if it is binary, same as git
+const textSniffBytes = 8000
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// check if an open file looks like text, meaning there are no NUL bytes near the start
+// the file is rewound to the start afterwards
+func isTextFile(file *os.File) (bool, error) {
+	buf := make([]byte, textSniffBytes)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return false, err
+	}
+	return bytes.IndexByte(buf[:n], 0) == -1, nil
+}
+
+// writer that passes text through with a leading UTF-8 BOM, CR before LF, and trailing spaces and tabs removed
+// so that the same text saved on Windows and Linux, or by different editors, gives the same bytes
+type textNormalizer struct {
+	writer  io.Writer
+	started bool   // set once the BOM has been checked for
+	head    []byte // first bytes of the stream, held until we know if they are a BOM
+	pending []byte // whitespace that is dropped if the line ends before anything else is written
+	out     []byte
+}
+
+func (n *textNormalizer) Write(p []byte) (int, error) {
+	total := len(p)
+	if !n.started {
+		n.head = append(n.head, p...)
+		if len(n.head) < len(utf8BOM) && bytes.HasPrefix(utf8BOM, n.head) {
+			return total, nil
+		}
+		p = bytes.TrimPrefix(n.head, utf8BOM)
+		n.started = true
+		n.head = nil
+	}
+
+	n.out = n.out[:0]
+	for _, b := range p {
+		switch b {
+		case ' ', '\t', '\r':
+			n.pending = append(n.pending, b)
+		case '\n':
+			n.pending = n.pending[:0]
+			n.out = append(n.out, b)
+		default:
+			n.out = append(n.out, n.pending...)
+			n.pending = n.pending[:0]
+			n.out = append(n.out, b)
+		}
+	}
+	_, err := n.writer.Write(n.out)
+	return total, err
+}
+
+// write out anything still held back at the end of the stream; trailing whitespace on the last line is dropped
+func (n *textNormalizer) Close() error {
+	if n.started {
+		return nil
+	}
+	// the whole stream was shorter than a BOM
+	n.started = true
+	_, err := n.Write(n.head)
+	return err
+}
+
+// get the hash of the normalized contents of an open text file
+// sampling is not used since the normalized stream does not line up with the file size
+func getNormalizedTextHash(inputFile *os.File, config HashConfig) (HashSum, error) {
+	newHash, err := GetHashAlgorithm(config.Algo)
+	if err != nil {
+		return HashSum{}, err
+	}
+	hashWriter := newHash()
+	normalizer := &textNormalizer{writer: hashWriter}
+	_, err = io.Copy(normalizer, inputFile)
+	if err != nil {
+		logger.Printf("Error encountered while hashing file: %v\n", err)
+		return HashSum{}, err
+	}
+	err = normalizer.Close()
+	if err != nil {
+		return HashSum{}, err
+	}
+	return NewHashSum(hashWriter.Sum(nil)), nil
+}
+
+// get the files that need to be hashed when comparing normalized text
+// text files can differ in size and still be the same once normalized, so every text file is included
+// along with any other files that have the same size as another file
+func FindTextSizeDupes(fileSizeMap map[int64][]FileEntry) (map[int64][]FileEntry, int) {
+	dupesMap, numDupes := FindSizeDupes(fileSizeMap)
+	for size, entries := range fileSizeMap {
+		if len(entries) != 1 {
+			continue
+		}
+		file, err := os.Open(entries[0].Path())
+		if err != nil {
+			// let the hashing step report the error
+			continue
+		}
+		isText, err := isTextFile(file)
+		file.Close()
+		if err == nil && isText {
+			dupesMap[size] = entries
+			numDupes += 1
+		}
+	}
+	return dupesMap, numDupes
+}